@@ -0,0 +1,36 @@
+// Package animation decodes the frames of animated GIF and APNG files as they
+// are displayed, each frame composed onto the canvas left by the previous ones.
+//
+// The standard library decodes only the first frame of an APNG and returns
+// the raw, uncomposed frames of a GIF, neither is what a viewer shows.
+package animation
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"os"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// DecodeFile decodes the frames of the animated GIF or APNG at path.
+func DecodeFile(path string) ([]*image.NRGBA, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(data)
+}
+
+// Decode returns the composed frames of an animated GIF or APNG. A still
+// image yields a single frame.
+func Decode(data []byte) ([]*image.NRGBA, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		return decodeGIF(data)
+	case bytes.HasPrefix(data, pngSignature):
+		return decodeAPNG(data)
+	}
+	return nil, fmt.Errorf("not a GIF or PNG file")
+}
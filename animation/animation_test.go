@@ -0,0 +1,163 @@
+package animation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"testing"
+)
+
+var (
+	red  = color.NRGBA{R: 255, A: 255}
+	blue = color.NRGBA{B: 255, A: 255}
+)
+
+func filled(r image.Rectangle, c color.Color) *image.NRGBA {
+	img := image.NewNRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func paletted(r image.Rectangle, c color.Color) *image.Paletted {
+	img := image.NewPaletted(r, palette.Plan9)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestDecodeGIF(t *testing.T) {
+	// A red canvas, a blue 2x2 patch kept, then a blue patch restored after display
+	anim := &gif.GIF{
+		Image: []*image.Paletted{
+			paletted(image.Rect(0, 0, 4, 4), red),
+			paletted(image.Rect(0, 0, 2, 2), blue),
+			paletted(image.Rect(2, 2, 4, 4), blue),
+			paletted(image.Rect(0, 0, 1, 1), red),
+		},
+		Delay:    []int{10, 10, 10, 10},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalNone, gif.DisposalPrevious, gif.DisposalNone},
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+
+	frames, err := Decode(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if len(frames) != 4 {
+		t.Fatalf("Decode() = %d frames, want 4", len(frames))
+	}
+	checks := []struct {
+		frame int
+		x, y  int
+		want  color.NRGBA
+	}{
+		{frame: 0, x: 1, y: 1, want: red},
+		{frame: 1, x: 1, y: 1, want: blue},
+		{frame: 1, x: 3, y: 3, want: red},
+		{frame: 2, x: 3, y: 3, want: blue},
+		{frame: 2, x: 0, y: 0, want: blue},
+		// Restored to the canvas before frame 3
+		{frame: 3, x: 3, y: 3, want: red},
+		{frame: 3, x: 1, y: 1, want: blue},
+	}
+	for _, c := range checks {
+		if got := frames[c.frame].NRGBAAt(c.x, c.y); got != c.want {
+			t.Errorf("frame %d at %d,%d = %v, want %v", c.frame, c.x, c.y, got, c.want)
+		}
+	}
+}
+
+// testAPNG builds an APNG whose default image is the first frame, followed by
+// a partial frame blended over it.
+func testAPNG(t *testing.T) []byte {
+	t.Helper()
+	first := pngParts(t, filled(image.Rect(0, 0, 4, 4), red))
+	second := pngParts(t, filled(image.Rect(0, 0, 2, 2), blue))
+
+	fcTL := func(seq uint32, r image.Rectangle, dispose, blend byte) []byte {
+		be := binary.BigEndian
+		data := be.AppendUint32(nil, seq)
+		data = be.AppendUint32(data, uint32(r.Dx()))
+		data = be.AppendUint32(data, uint32(r.Dy()))
+		data = be.AppendUint32(data, uint32(r.Min.X))
+		data = be.AppendUint32(data, uint32(r.Min.Y))
+		data = be.AppendUint16(be.AppendUint16(data, 1), 10)
+		return append(data, dispose, blend)
+	}
+	out := append([]byte(nil), pngSignature...)
+	out = appendChunk(out, "IHDR", first["IHDR"])
+	out = appendChunk(out, "acTL", []byte{0, 0, 0, 2, 0, 0, 0, 0})
+	out = appendChunk(out, "fcTL", fcTL(0, image.Rect(0, 0, 4, 4), apngDisposeNone, apngBlendSource))
+	out = appendChunk(out, "IDAT", first["IDAT"])
+	out = appendChunk(out, "fcTL", fcTL(1, image.Rect(1, 1, 3, 3), apngDisposeNone, 1))
+	out = appendChunk(out, "fdAT", append([]byte{0, 0, 0, 2}, second["IDAT"]...))
+	return appendChunk(out, "IEND", nil)
+}
+
+// pngParts encodes img and returns its IHDR and concatenated IDAT data.
+func pngParts(t *testing.T, img image.Image) map[string][]byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := pngChunks(buf.Bytes()[len(pngSignature):])
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := map[string][]byte{}
+	for _, chunk := range chunks {
+		parts[chunk.Type] = append(parts[chunk.Type], chunk.Data...)
+	}
+	return parts
+}
+
+func TestDecodeAPNG(t *testing.T) {
+	frames, err := Decode(testAPNG(t))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("Decode() = %d frames, want 2", len(frames))
+	}
+	if got := frames[0].NRGBAAt(1, 1); got != red {
+		t.Errorf("frame 1 = %v, want red", got)
+	}
+	if got := frames[1].NRGBAAt(1, 1); got != blue {
+		t.Errorf("frame 2 inside the patch = %v, want blue", got)
+	}
+	if got := frames[1].NRGBAAt(0, 0); got != red {
+		t.Errorf("frame 2 outside the patch = %v, want red", got)
+	}
+}
+
+func TestDecodeStillPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, filled(image.Rect(0, 0, 3, 3), red)); err != nil {
+		t.Fatal(err)
+	}
+	frames, err := Decode(buf.Bytes())
+	if err != nil || len(frames) != 1 {
+		t.Fatalf("Decode() = %d frames, %v, want 1 frame", len(frames), err)
+	}
+}
+
+func TestDecodeRejectsOtherFormats(t *testing.T) {
+	if _, err := Decode([]byte{0xff, 0xd8, 0xff}); err == nil {
+		t.Error("Decode() of a JPEG, want error")
+	}
+}
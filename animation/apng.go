@@ -0,0 +1,175 @@
+package animation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/png"
+)
+
+// APNG frame control operations.
+const (
+	apngDisposeNone       = 0
+	apngDisposeBackground = 1
+	apngDisposePrevious   = 2
+	apngBlendSource       = 0
+	fcTLSize              = 26
+)
+
+type pngChunk struct {
+	Type string
+	Data []byte
+}
+
+// apngFrame is a frame control chunk and the image data following it.
+type apngFrame struct {
+	Bounds  image.Rectangle
+	Dispose byte
+	Blend   byte
+	Data    [][]byte
+}
+
+// decodeAPNG composes the frames of an APNG. Each frame is decoded by
+// image/png as a standalone PNG built from the header chunks and its data.
+func decodeAPNG(data []byte) ([]*image.NRGBA, error) {
+	chunks, err := pngChunks(data[len(pngSignature):])
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 || chunks[0].Type != "IHDR" || len(chunks[0].Data) != 13 {
+		return nil, fmt.Errorf("png: missing IHDR chunk")
+	}
+	ihdr := chunks[0].Data
+	width := int(binary.BigEndian.Uint32(ihdr[0:]))
+	height := int(binary.BigEndian.Uint32(ihdr[4:]))
+
+	// Chunks needed to decode any frame, e.g. the palette and transparency
+	var header []pngChunk
+	var frames []*apngFrame
+	var current *apngFrame
+	animated := false
+	for _, chunk := range chunks[1:] {
+		switch chunk.Type {
+		case "acTL":
+			animated = true
+		case "fcTL":
+			if len(chunk.Data) != fcTLSize {
+				return nil, fmt.Errorf("apng: invalid fcTL chunk")
+			}
+			w := int(binary.BigEndian.Uint32(chunk.Data[4:]))
+			h := int(binary.BigEndian.Uint32(chunk.Data[8:]))
+			x := int(binary.BigEndian.Uint32(chunk.Data[12:]))
+			y := int(binary.BigEndian.Uint32(chunk.Data[16:]))
+			bounds := image.Rect(x, y, x+w, y+h)
+			if w == 0 || h == 0 || !bounds.In(image.Rect(0, 0, width, height)) {
+				return nil, fmt.Errorf("apng: frame %v outside of the %dx%d canvas", bounds, width, height)
+			}
+			current = &apngFrame{Bounds: bounds, Dispose: chunk.Data[24], Blend: chunk.Data[25]}
+			frames = append(frames, current)
+		case "IDAT":
+			// Without a preceding fcTL the default image is not part of the animation
+			if current != nil {
+				current.Data = append(current.Data, chunk.Data)
+			}
+		case "fdAT":
+			if current == nil || len(chunk.Data) < 4 {
+				return nil, fmt.Errorf("apng: fdAT chunk without frame control")
+			}
+			// The sequence number precedes the image data
+			current.Data = append(current.Data, chunk.Data[4:])
+		case "IEND":
+		default:
+			if current == nil {
+				header = append(header, chunk)
+			}
+		}
+	}
+	if !animated {
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return []*image.NRGBA{toNRGBA(img)}, nil
+	}
+
+	canvas := image.NewNRGBA(image.Rect(0, 0, width, height))
+	result := make([]*image.NRGBA, 0, len(frames))
+	for i, frame := range frames {
+		img, err := png.Decode(bytes.NewReader(framePNG(ihdr, header, frame)))
+		if err != nil {
+			return nil, fmt.Errorf("apng frame %d: %w", i+1, err)
+		}
+		var previous *image.NRGBA
+		if frame.Dispose == apngDisposePrevious {
+			previous = cloneNRGBA(canvas)
+		}
+		op := draw.Over
+		if frame.Blend == apngBlendSource {
+			op = draw.Src
+		}
+		draw.Draw(canvas, frame.Bounds, img, img.Bounds().Min, op)
+		result = append(result, cloneNRGBA(canvas))
+
+		switch {
+		case frame.Dispose == apngDisposeBackground, frame.Dispose == apngDisposePrevious && i == 0:
+			draw.Draw(canvas, frame.Bounds, image.Transparent, image.Point{}, draw.Src)
+		case frame.Dispose == apngDisposePrevious:
+			canvas = previous
+		}
+	}
+	return result, nil
+}
+
+// framePNG builds a standalone PNG of a frame.
+func framePNG(ihdr []byte, header []pngChunk, frame *apngFrame) []byte {
+	frameHeader := bytes.Clone(ihdr)
+	binary.BigEndian.PutUint32(frameHeader[0:], uint32(frame.Bounds.Dx()))
+	binary.BigEndian.PutUint32(frameHeader[4:], uint32(frame.Bounds.Dy()))
+
+	out := append([]byte(nil), pngSignature...)
+	out = appendChunk(out, "IHDR", frameHeader)
+	for _, chunk := range header {
+		out = appendChunk(out, chunk.Type, chunk.Data)
+	}
+	out = appendChunk(out, "IDAT", bytes.Join(frame.Data, nil))
+	return appendChunk(out, "IEND", nil)
+}
+
+func appendChunk(out []byte, chunkType string, data []byte) []byte {
+	out = binary.BigEndian.AppendUint32(out, uint32(len(data)))
+	start := len(out)
+	out = append(out, chunkType...)
+	out = append(out, data...)
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(out[start:]))
+}
+
+func pngChunks(data []byte) ([]pngChunk, error) {
+	var chunks []pngChunk
+	for len(data) > 0 {
+		if len(data) < 12 {
+			return nil, fmt.Errorf("png: truncated chunk")
+		}
+		length := binary.BigEndian.Uint32(data[:4])
+		if uint64(length) > uint64(len(data)-12) {
+			return nil, fmt.Errorf("png chunk %s: truncated", data[4:8])
+		}
+		chunks = append(chunks, pngChunk{Type: string(data[4:8]), Data: data[8 : 8+length]})
+		if string(data[4:8]) == "IEND" {
+			break
+		}
+		data = data[12+length:]
+	}
+	return chunks, nil
+}
+
+func toNRGBA(img image.Image) *image.NRGBA {
+	if nrgba, ok := img.(*image.NRGBA); ok {
+		return nrgba
+	}
+	dst := image.NewNRGBA(img.Bounds())
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Src)
+	return dst
+}
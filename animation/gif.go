@@ -0,0 +1,47 @@
+package animation
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/gif"
+)
+
+func decodeGIF(data []byte) ([]*image.NRGBA, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	canvas := image.NewNRGBA(bounds)
+	frames := make([]*image.NRGBA, 0, len(g.Image))
+	for i, frame := range g.Image {
+		var previous *image.NRGBA
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = cloneNRGBA(canvas)
+		}
+
+		// Transparent pixels of a frame show the canvas below
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		frames = append(frames, cloneNRGBA(canvas))
+
+		switch disposal {
+		case gif.DisposalBackground:
+			// Viewers clear to transparent instead of the background color
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return frames, nil
+}
+
+func cloneNRGBA(img *image.NRGBA) *image.NRGBA {
+	clone := image.NewNRGBA(img.Bounds())
+	copy(clone.Pix, img.Pix)
+	return clone
+}
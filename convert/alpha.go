@@ -0,0 +1,121 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// AlphaPolicy decides how sources with transparent pixels are converted, JPEG
+// has no alpha channel and cjpegli's handling of it is not defined.
+type AlphaPolicy string
+
+const (
+	// AlphaFlatten composes the image onto Options.AlphaBackground
+	AlphaFlatten AlphaPolicy = "flatten"
+	// AlphaSkip leaves transparent images untouched
+	AlphaSkip AlphaPolicy = "skip"
+	// AlphaKeepPNG leaves transparent images untouched like AlphaSkip, folder
+	// runs copy them unchanged into the output folder. Runs without an output
+	// folder (single files, in-place folders) treat it exactly like AlphaSkip,
+	// the original already stays where it is
+	AlphaKeepPNG AlphaPolicy = "keep_png"
+)
+
+// ErrTransparentSkipped is returned for transparent images with AlphaSkip or AlphaKeepPNG.
+var ErrTransparentSkipped = errors.New("skipped transparent image")
+
+// defaultAlphaBackground is white, the background most logos and screenshots
+// are designed for.
+var defaultAlphaBackground = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+
+func (p AlphaPolicy) normalize() (AlphaPolicy, error) {
+	switch p {
+	case "":
+		return AlphaFlatten, nil
+	case AlphaFlatten, AlphaSkip, AlphaKeepPNG:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown alpha policy: %q", p)
+}
+
+// parseBackground parses a color as "#rrggbb", empty is white.
+func parseBackground(value string) (color.NRGBA, error) {
+	if value == "" {
+		return defaultAlphaBackground, nil
+	}
+	hex, ok := strings.CutPrefix(value, "#")
+	if !ok || len(hex) != 6 {
+		return color.NRGBA{}, fmt.Errorf("invalid alpha background %q, expected #rrggbb", value)
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid alpha background %q, expected #rrggbb", value)
+	}
+	return color.NRGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 0xff}, nil
+}
+
+// opaqueImage is implemented by the image types of the standard library, it
+// checks every pixel.
+type opaqueImage interface {
+	Opaque() bool
+}
+
+// transparentImage decodes path and returns the image if it has at least one
+// pixel that is not fully opaque. Formats Go cannot decode (JPEG XL, PNM) and
+// formats without alpha yield nil.
+func transparentImage(path string) image.Image {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	// JPEG has no alpha, skip decoding it
+	config, _, err := image.DecodeConfig(f)
+	if err != nil || !hasAlpha(config.ColorModel) {
+		return nil
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil
+	}
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil
+	}
+	if o, ok := img.(opaqueImage); ok && o.Opaque() {
+		return nil
+	}
+	return img
+}
+
+// hasAlpha reports whether images of model can hold transparent pixels.
+func hasAlpha(model color.Model) bool {
+	switch model {
+	case color.GrayModel, color.Gray16Model, color.YCbCrModel, color.CMYKModel:
+		return false
+	}
+	if palette, ok := model.(color.Palette); ok {
+		for _, c := range palette {
+			if _, _, _, a := c.RGBA(); a != 0xffff {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// flatten composes img onto background and writes it as PNG into dir, the
+// caller removes the returned file.
+func flatten(img image.Image, background color.NRGBA, dir string) (string, error) {
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Over)
+	return writeTempPNG(dst, dir)
+}
@@ -0,0 +1,88 @@
+package convert
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseBackground(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    color.NRGBA
+		wantErr bool
+	}{
+		{value: "", want: defaultAlphaBackground},
+		{value: "#ff8000", want: color.NRGBA{R: 0xff, G: 0x80, A: 0xff}},
+		{value: "#FFFFFF", want: defaultAlphaBackground},
+		{value: "ffffff", wantErr: true},
+		{value: "#fff", wantErr: true},
+		{value: "#gggggg", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseBackground(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("parseBackground(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("parseBackground(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestTransparentImageAndFlatten(t *testing.T) {
+	dir := t.TempDir()
+	logo := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	logo.SetNRGBA(0, 0, color.NRGBA{R: 255, A: 255})
+	// Half transparent black
+	logo.SetNRGBA(1, 0, color.NRGBA{A: 128})
+	transparentPath := filepath.Join(dir, "logo.png")
+	writeImage(t, transparentPath, logo)
+	opaquePath := filepath.Join(dir, "opaque.png")
+	writeTestImage(t, opaquePath, 4, 4)
+	jpegPath := filepath.Join(dir, "photo.jpg")
+	writeTestImage(t, jpegPath, 4, 4)
+
+	if transparentImage(opaquePath) != nil {
+		t.Error("transparentImage(opaque png) != nil")
+	}
+	if transparentImage(jpegPath) != nil {
+		t.Error("transparentImage(jpeg) != nil")
+	}
+	img := transparentImage(transparentPath)
+	if img == nil {
+		t.Fatal("transparentImage(transparent png) = nil")
+	}
+
+	flat, err := flatten(img, color.NRGBA{R: 255, G: 255, B: 255, A: 255}, dir)
+	if err != nil {
+		t.Fatalf("flatten() error = %v", err)
+	}
+	defer os.Remove(flat)
+	f, err := os.Open(flat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	out, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := []struct {
+		x, y int
+		want color.NRGBA
+	}{
+		{x: 0, y: 0, want: color.NRGBA{R: 255, A: 255}},
+		{x: 1, y: 0, want: color.NRGBA{R: 127, G: 127, B: 127, A: 255}},
+		{x: 3, y: 3, want: color.NRGBA{R: 255, G: 255, B: 255, A: 255}},
+	}
+	for _, c := range checks {
+		got := color.NRGBAModel.Convert(out.At(c.x, c.y)).(color.NRGBA)
+		if got != c.want {
+			t.Errorf("flattened pixel %d,%d = %v, want %v", c.x, c.y, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dhcgn/jpegli-windows-explorer-extension/animation"
+	"github.com/dhcgn/jpegli-windows-explorer-extension/imagemeta"
+	"github.com/dhcgn/jpegli-windows-explorer-extension/types"
+)
+
+// AnimationPolicy decides how animated GIF and APNG sources are converted, a
+// JPEG holds a single frame.
+type AnimationPolicy string
+
+const (
+	// AnimationFirstFrame converts the first frame and reports the dropped ones
+	AnimationFirstFrame AnimationPolicy = "first_frame"
+	// AnimationSkip leaves animated files untouched
+	AnimationSkip AnimationPolicy = "skip"
+	// AnimationFrames writes every frame as numbered JPEG into a folder named
+	// after the output
+	AnimationFrames AnimationPolicy = "frames"
+)
+
+// ErrAnimationSkipped is returned when a file is skipped due to AnimationSkip.
+var ErrAnimationSkipped = errors.New("skipped animated file")
+
+func (p AnimationPolicy) normalize() (AnimationPolicy, error) {
+	switch p {
+	case "":
+		return AnimationFirstFrame, nil
+	case AnimationFirstFrame, AnimationSkip, AnimationFrames:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown animation policy: %q", p)
+}
+
+// animationFrames returns the frame count of an animated GIF or APNG, 0 for
+// still images and files that cannot be read, which are converted as before.
+func animationFrames(sourcePath string) int {
+	native, err := imagemeta.Read(sourcePath)
+	if err != nil {
+		return 0
+	}
+	return native.Frames
+}
+
+// framesFolder returns the folder of the frames of targetPath, e.g.
+// "anim.jpegli" for "anim.jpegli.jpg".
+func framesFolder(targetPath string) string {
+	return strings.TrimSuffix(targetPath, filepath.Ext(targetPath))
+}
+
+// convertFrames encodes every frame of an animated source as a separate JPEG
+// named "<folder>-0001.jpg" inside framesFolder(targetPath). Each frame runs
+// through the complete conversion, metadata and provenance come from the
+// source. Existing frames are replaced.
+func convertFrames(tools types.ExecutablePaths, opts Options, sourcePath, targetPath, markerValue string) (ConvertStats, error) {
+	frames, err := animation.DecodeFile(sourcePath)
+	if err != nil {
+		return ConvertStats{}, fmt.Errorf("error decoding frames of %s: %w", sourcePath, err)
+	}
+	folder := framesFolder(targetPath)
+	if err := os.MkdirAll(folder, os.ModePerm); err != nil {
+		return ConvertStats{}, fmt.Errorf("failed to create frames folder: %w", err)
+	}
+
+	// The source is never replaced by its frames
+	opts.OverrideOriginal = false
+	var total ConvertStats
+	for i, frame := range frames {
+		framePath, err := writeTempPNG(frame, folder)
+		if err != nil {
+			return ConvertStats{}, fmt.Errorf("error writing frame %d: %w", i+1, err)
+		}
+		target := filepath.Join(folder, fmt.Sprintf("%s-%04d%s", filepath.Base(folder), i+1, filepath.Ext(targetPath)))
+		stats, err := convertFile(tools, opts, sourcePath, framePath, target, markerValue)
+		os.Remove(framePath)
+		if err != nil {
+			// Leaves the folder if frames were written before
+			os.Remove(folder)
+			return ConvertStats{}, fmt.Errorf("frame %d: %w", i+1, err)
+		}
+		if i == 0 {
+			total = stats
+		} else {
+			total.TargetSize += stats.TargetSize
+			total.Warnings = append(total.Warnings, stats.Warnings...)
+		}
+	}
+	total.SavedSize = total.SourceSize - total.TargetSize
+	total.FileSizeRatio = float64(total.TargetSize) / float64(total.SourceSize)
+	total.AnimationFrames = len(frames)
+	total.FramesFolder = folder
+	total.FramesExported = len(frames)
+	return total, nil
+}
+
+// writeTempPNG writes img into dir as cjpegli input, the caller removes it.
+func writeTempPNG(img image.Image, dir string) (string, error) {
+	out, err := os.CreateTemp(dir, ".jpegli-*.png")
+	if err != nil {
+		return "", err
+	}
+	encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err := encoder.Encode(out, img); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
@@ -0,0 +1,13 @@
+package convert
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFramesFolder(t *testing.T) {
+	target := filepath.Join("out", "anim.jpegli.jpg")
+	if got, want := framesFolder(target), filepath.Join("out", "anim.jpegli"); got != want {
+		t.Errorf("framesFolder(%q) = %q, want %q", target, got, want)
+	}
+}
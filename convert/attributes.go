@@ -0,0 +1,47 @@
+package convert
+
+import (
+	"fmt"
+	"os"
+)
+
+// defaultOutputMode is applied to outputs when the source permissions are not preserved.
+const defaultOutputMode os.FileMode = 0644
+
+// preserveAttributes copies the extended attributes of sourcePath to
+// targetPath, if selected in opts.
+func preserveAttributes(opts Options, sourcePath, targetPath string) error {
+	if opts.PreserveExtendedAttributes {
+		if err := copyExtendedAttributes(sourcePath, targetPath); err != nil {
+			return fmt.Errorf("failed to copy extended attributes: %w", err)
+		}
+	}
+	return nil
+}
+
+// preserveTimes copies access and modification times from sourceInfo to
+// targetPath, if selected in opts. It must run after the last access to
+// targetPath, as any later write updates the modification time and any later
+// read may update the access time again.
+func preserveTimes(opts Options, sourceInfo os.FileInfo, targetPath string) error {
+	if opts.PreserveTimestamps {
+		if err := os.Chtimes(targetPath, accessTime(sourceInfo), sourceInfo.ModTime()); err != nil {
+			return fmt.Errorf("failed to copy timestamps: %w", err)
+		}
+	}
+	return nil
+}
+
+// applyMode sets the permissions of targetPath to those of the source or to
+// defaultOutputMode. This runs after syncing, because a read-only mode would
+// prevent syncing the file afterwards on Windows.
+func applyMode(opts Options, sourceInfo os.FileInfo, targetPath string) error {
+	mode := defaultOutputMode
+	if opts.PreservePermissions {
+		mode = sourceInfo.Mode().Perm()
+	}
+	if err := os.Chmod(targetPath, mode); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+//go:build linux
+// +build linux
+
+package convert
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func accessTime(info os.FileInfo) time.Time {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(stat.Atim.Sec, stat.Atim.Nsec)
+	}
+	return info.ModTime()
+}
+
+// copyExtendedAttributes copies all extended attributes from sourcePath to targetPath.
+// Attributes the filesystem or the current user cannot write are silently skipped.
+func copyExtendedAttributes(sourcePath, targetPath string) error {
+	size, err := unix.Listxattr(sourcePath, nil)
+	if err != nil {
+		if isXattrUnsupported(err) {
+			return nil
+		}
+		return err
+	}
+	if size == 0 {
+		return nil
+	}
+	buf := make([]byte, size)
+	size, err = unix.Listxattr(sourcePath, buf)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range strings.Split(strings.TrimRight(string(buf[:size]), "\x00"), "\x00") {
+		if name == "" {
+			continue
+		}
+		valueSize, err := unix.Getxattr(sourcePath, name, nil)
+		if err != nil {
+			return err
+		}
+		value := make([]byte, valueSize)
+		valueSize, err = unix.Getxattr(sourcePath, name, value)
+		if err != nil {
+			return err
+		}
+		if err := unix.Setxattr(targetPath, name, value[:valueSize], 0); err != nil && !isXattrUnsupported(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func isXattrUnsupported(err error) bool {
+	return errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EPERM) || errors.Is(err, unix.EACCES)
+}
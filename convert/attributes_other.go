@@ -0,0 +1,18 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package convert
+
+import (
+	"os"
+	"time"
+)
+
+func accessTime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
+
+// copyExtendedAttributes is a no-op on platforms without extended attribute support.
+func copyExtendedAttributes(sourcePath, targetPath string) error {
+	return nil
+}
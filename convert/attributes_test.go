@@ -0,0 +1,88 @@
+package convert
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestPreserveAttributes(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.jpg")
+	target := filepath.Join(dir, "target.jpg")
+	if err := os.WriteFile(source, []byte("source"), 0640); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+	if err := os.WriteFile(target, []byte("target"), 0600); err != nil {
+		t.Fatalf("Failed to write target: %v", err)
+	}
+	modTime := time.Date(2020, 5, 17, 10, 30, 0, 0, time.UTC)
+	if err := os.Chtimes(source, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set source times: %v", err)
+	}
+	sourceInfo, err := os.Stat(source)
+	if err != nil {
+		t.Fatalf("Failed to stat source: %v", err)
+	}
+
+	opts := Options{PreserveTimestamps: true, PreservePermissions: true, PreserveExtendedAttributes: true}
+	if err := preserveAttributes(opts, source, target); err != nil {
+		t.Fatalf("preserveAttributes() error = %v", err)
+	}
+	if err := applyMode(opts, sourceInfo, target); err != nil {
+		t.Fatalf("applyMode() error = %v", err)
+	}
+	// Reading the target, as the output verification does, must not lose the times
+	if _, err := os.ReadFile(target); err != nil {
+		t.Fatalf("Failed to read target: %v", err)
+	}
+	if err := preserveTimes(opts, sourceInfo, target); err != nil {
+		t.Fatalf("preserveTimes() error = %v", err)
+	}
+
+	targetInfo, err := os.Stat(target)
+	if err != nil {
+		t.Fatalf("Failed to stat target: %v", err)
+	}
+	if !targetInfo.ModTime().Equal(modTime) {
+		t.Errorf("Expected modification time %v, got %v", modTime, targetInfo.ModTime())
+	}
+	if !accessTime(targetInfo).Equal(modTime) {
+		t.Errorf("Expected access time %v, got %v", modTime, accessTime(targetInfo))
+	}
+	if runtime.GOOS != "windows" && targetInfo.Mode().Perm() != 0640 {
+		t.Errorf("Expected mode %v, got %v", os.FileMode(0640), targetInfo.Mode().Perm())
+	}
+}
+
+func TestApplyModeDefault(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not supported on Windows")
+	}
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.jpg")
+	target := filepath.Join(dir, "target.jpg")
+	if err := os.WriteFile(source, []byte("source"), 0600); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+	if err := os.WriteFile(target, []byte("target"), 0600); err != nil {
+		t.Fatalf("Failed to write target: %v", err)
+	}
+	sourceInfo, err := os.Stat(source)
+	if err != nil {
+		t.Fatalf("Failed to stat source: %v", err)
+	}
+
+	if err := applyMode(Options{}, sourceInfo, target); err != nil {
+		t.Fatalf("applyMode() error = %v", err)
+	}
+	targetInfo, err := os.Stat(target)
+	if err != nil {
+		t.Fatalf("Failed to stat target: %v", err)
+	}
+	if targetInfo.Mode().Perm() != defaultOutputMode {
+		t.Errorf("Expected mode %v, got %v", defaultOutputMode, targetInfo.Mode().Perm())
+	}
+}
@@ -0,0 +1,22 @@
+//go:build windows
+// +build windows
+
+package convert
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+func accessTime(info os.FileInfo) time.Time {
+	if data, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		return time.Unix(0, data.LastAccessTime.Nanoseconds())
+	}
+	return info.ModTime()
+}
+
+// copyExtendedAttributes is a no-op on Windows, NTFS alternate data streams are not copied.
+func copyExtendedAttributes(sourcePath, targetPath string) error {
+	return nil
+}
@@ -6,7 +6,10 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/dhcgn/jpegli-windows-explorer-extension/filehandling"
+	"github.com/dhcgn/jpegli-windows-explorer-extension/imagemeta"
 	"github.com/dhcgn/jpegli-windows-explorer-extension/types"
 )
 
@@ -15,11 +18,142 @@ type ConvertStats struct {
 	TargetSize    int64
 	SourceSize    int64
 	SavedSize     int64
+	// Sidecars lists the XMP sidecars merged or copied for this file
+	Sidecars []string
+	// AnimationFrames is the frame count of an animated source, 0 for still images
+	AnimationFrames int
+	// FramesFolder holds the frames exported by AnimationFrames, FramesExported
+	// is their number
+	FramesFolder   string
+	FramesExported int
+	// FlattenedOnto is the background transparent pixels were composed onto,
+	// e.g. "#ffffff", empty if the source was opaque
+	FlattenedOnto string
+	// Oriented is the EXIF orientation applied to the pixels, 0 if not rotated
+	Oriented int
+	// Grayscale is set if a color source with gray content was encoded as
+	// single channel JPEG, GrayscaleSaved is what that saved over encoding it
+	// in color
+	Grayscale      bool
+	GrayscaleSaved int64
+	// SourceQuality is the estimated quality of a JPEG source, zero for other formats
+	SourceQuality imagemeta.QualityEstimate
+	// Transplanted lists the segments copied verbatim from the source, e.g. APP13
+	Transplanted []string
+	// Provenance is what the processed marker of the output records
+	Provenance Provenance
+	// MetadataDiff compares the tags of source and output, nil unless
+	// Options.VerifyMetadata is set
+	MetadataDiff *MetadataDiff
+	// Metadata describes the metadata policy applied to the output
+	Metadata string
+	// Warnings lists problems that did not fail the conversion
+	Warnings []string
+}
+
+// Options controls how Convert encodes and writes a single file.
+type Options struct {
+	// Distance is the jpegli distance, 0.0 to 25.0 (1.0 = visually lossless)
+	Distance float64
+	// OverrideOriginal replaces the source file instead of writing to the target path
+	OverrideOriginal bool
+	// PreserveTimestamps copies access and modification times from the source
+	PreserveTimestamps bool
+	// PreservePermissions copies the file mode from the source
+	PreservePermissions bool
+	// PreserveExtendedAttributes copies extended attributes where the platform supports them
+	PreserveExtendedAttributes bool
+	// LinkPolicy controls how OverrideOriginal handles symlinks and hard-linked files
+	LinkPolicy LinkPolicy
+	// SidecarPolicy controls how XMP sidecars of the source are carried over
+	SidecarPolicy SidecarPolicy
+	// MetadataPolicy controls which tags are copied from the source, MetadataAllowTags
+	// and MetadataDenyTags are used with MetadataCustom
+	MetadataPolicy    MetadataPolicy
+	MetadataAllowTags []string
+	MetadataDenyTags  []string
+	// Artist, Copyright and Credit are written to the matching EXIF, XMP and IPTC tags
+	Artist    string
+	Copyright string
+	Credit    string
+	// VerifyMetadata compares the tags of source and output after conversion
+	VerifyMetadata bool
+	// Profile is the name of the configuration, recorded in the provenance tags
+	Profile string
+	// MultiPicturePolicy controls what happens to secondary images of the source,
+	// e.g. Ultra HDR gain maps and depth maps
+	MultiPicturePolicy MultiPicturePolicy
+	// AnimationPolicy controls how animated GIF and APNG sources are converted
+	AnimationPolicy AnimationPolicy
+	// UnusualJPEGPolicy controls how CMYK, 12-bit, arithmetic coded and other
+	// JPEGs that may not round-trip through cjpegli are handled
+	UnusualJPEGPolicy UnusualJPEGPolicy
+	// MinSourceQuality skips JPEG sources whose estimated quality is below it,
+	// 0 converts all
+	MinSourceQuality int
+	// MatchSourceQuality lowers the distance for JPEG sources of higher quality
+	// than the distance keeps, so they are not degraded further
+	MatchSourceQuality bool
+	// DetectGrayscale encodes color sources whose channels differ by at most
+	// GrayscaleTolerance levels (0 to 255) as single channel JPEG
+	DetectGrayscale    bool
+	GrayscaleTolerance int
+	// AlphaPolicy controls how sources with transparent pixels are converted,
+	// AlphaBackground is the "#rrggbb" color AlphaFlatten composes them onto
+	AlphaPolicy     AlphaPolicy
+	AlphaBackground string
+	// NormalizeOrientation rotates and mirrors the pixels as the EXIF orientation
+	// describes and resets the orientation to 1
+	NormalizeOrientation bool
+	// ThumbnailPolicy controls what happens to the EXIF thumbnail of the source
+	ThumbnailPolicy ThumbnailPolicy
+	// TransplantSegments names the segments copied verbatim from the source after
+	// all metadata steps, "APP0" to "APP15" except APP1 and APP2, or "COM"
+	TransplantSegments []string
+	// SetTags are additional tags written to every output, values containing "$"
+	// are exiftool templates filled from the source, e.g. "$Make $Model"
+	SetTags map[string]string
 }
 
 const OptimizedByTag = "XMP-jpegli:OptimizedBy"
 
-func Convert(tools types.ExecutablePaths, distance float64, overrideOriginal bool, sourcePath, targetPath, markerValue string) (ConvertStats, error) {
+// Convert encodes sourcePath with cjpegli and writes the result to targetPath,
+// or replaces sourcePath when opts.OverrideOriginal is set.
+//
+// Every step (encoding, metadata copy, marker) runs on a temporary file in the
+// destination directory. Only after the temporary file has been synced to disk
+// and verified it is atomically renamed over the destination and the directory
+// is synced, so a crash at any point leaves either the previous file or the
+// complete new file behind. Linked files are handled according to opts.LinkPolicy.
+//
+// Animated GIF and APNG sources are handled according to opts.AnimationPolicy.
+func Convert(tools types.ExecutablePaths, opts Options, sourcePath, targetPath, markerValue string) (ConvertStats, error) {
+	animationPolicy, err := opts.AnimationPolicy.normalize()
+	if err != nil {
+		return ConvertStats{}, err
+	}
+	frames := animationFrames(sourcePath)
+	if frames == 0 {
+		return convertFile(tools, opts, sourcePath, sourcePath, targetPath, markerValue)
+	}
+	switch animationPolicy {
+	case AnimationSkip:
+		return ConvertStats{}, fmt.Errorf("%w (%d frames): %s", ErrAnimationSkipped, frames, sourcePath)
+	case AnimationFrames:
+		return convertFrames(tools, opts, sourcePath, targetPath, markerValue)
+	}
+	stats, err := convertFile(tools, opts, sourcePath, sourcePath, targetPath, markerValue)
+	if err != nil {
+		return ConvertStats{}, err
+	}
+	stats.AnimationFrames = frames
+	stats.Warnings = append(stats.Warnings, fmt.Sprintf("animation with %d frames, only the first frame was converted", frames))
+	return stats, nil
+}
+
+// convertFile converts a single image. The pixels are read from encoderInput,
+// everything else (metadata, attributes, provenance) from sourcePath.
+func convertFile(tools types.ExecutablePaths, opts Options, sourcePath, encoderInput, targetPath, markerValue string) (ConvertStats, error) {
 	// Validate tools paths
 	if tools.Cjpegli == "" {
 		return ConvertStats{}, fmt.Errorf("cjpegli path is empty")
@@ -43,69 +177,334 @@ func Convert(tools types.ExecutablePaths, distance float64, overrideOriginal boo
 	// Default to 1.0 if not specified (visually lossless)
 	// Allowed range is 0.0 to 25.0
 	distanceValue := 1.0
-	if distance >= 0.0 && distance <= 25.0 {
-		distanceValue = distance
-	} else if distance > 25.0 {
+	if opts.Distance >= 0.0 && opts.Distance <= 25.0 {
+		distanceValue = opts.Distance
+	} else if opts.Distance > 25.0 {
 		distanceValue = 25.0
-	} else if distance < 0.0 {
+	} else if opts.Distance < 0.0 {
 		distanceValue = 0.0
 	}
 
-	// Determine the actual target path (temporary or final)
-	actualTargetPath := targetPath
-	var tempFile *os.File
-	if overrideOriginal {
-		// Use a temporary file if we're going to override the original
-		// Create temp file in the same directory as source to ensure we're on the same filesystem
-		dir := filepath.Dir(sourcePath)
-		var err error
-		tempFile, err = os.CreateTemp(dir, ".jpegli-*.tmp")
+	metadata, err := newMetadataFilter(opts.MetadataPolicy, opts.MetadataAllowTags, opts.MetadataDenyTags)
+	if err != nil {
+		return ConvertStats{}, err
+	}
+	sidecarPolicy, err := opts.SidecarPolicy.normalize()
+	if err != nil {
+		return ConvertStats{}, err
+	}
+	var sidecars []string
+	if sidecarPolicy != SidecarIgnore {
+		sidecars = filehandling.FindSidecars(sourcePath)
+	}
+
+	thumbnailPolicy, err := opts.ThumbnailPolicy.normalize()
+	if err != nil {
+		return ConvertStats{}, err
+	}
+	unusualPolicy, err := opts.UnusualJPEGPolicy.normalize()
+	if err != nil {
+		return ConvertStats{}, err
+	}
+	toRGB, warnings, err := checkUnusualJPEG(unusualPolicy, encoderInput)
+	if err != nil {
+		return ConvertStats{}, err
+	}
+	sourceQuality, distanceValue, err := checkSourceQuality(opts.MinSourceQuality, opts.MatchSourceQuality, encoderInput, distanceValue)
+	if err != nil {
+		return ConvertStats{}, err
+	}
+	alphaPolicy, err := opts.AlphaPolicy.normalize()
+	if err != nil {
+		return ConvertStats{}, err
+	}
+	background, err := parseBackground(opts.AlphaBackground)
+	if err != nil {
+		return ConvertStats{}, err
+	}
+	transparent := transparentImage(encoderInput)
+	if transparent != nil && alphaPolicy != AlphaFlatten {
+		return ConvertStats{}, fmt.Errorf("%w: %s", ErrTransparentSkipped, sourcePath)
+	}
+	transplant, err := transplantMarkers(opts.TransplantSegments)
+	if err != nil {
+		return ConvertStats{}, err
+	}
+	multiPicturePolicy, err := opts.MultiPicturePolicy.normalize()
+	if err != nil {
+		return ConvertStats{}, err
+	}
+	multiPicture, multiPictureWarnings, err := checkMultiPicture(multiPicturePolicy, sourcePath)
+	if err != nil {
+		return ConvertStats{}, err
+	}
+	warnings = append(warnings, multiPictureWarnings...)
+
+	// The final destination is the source itself when overriding
+	finalPath := targetPath
+	inPlace := false
+	if opts.OverrideOriginal {
+		finalPath = sourcePath
+		linkPolicy, err := opts.LinkPolicy.normalize()
+		if err != nil {
+			return ConvertStats{}, err
+		}
+		linkInfo, err := filehandling.GetLinkInfo(sourcePath)
 		if err != nil {
-			return ConvertStats{}, fmt.Errorf("failed to create temporary file: %w", err)
+			return ConvertStats{}, fmt.Errorf("error getting link info: %w", err)
+		}
+		if linkInfo.IsLinked() {
+			switch linkPolicy {
+			case LinkPolicySkip:
+				return ConvertStats{}, fmt.Errorf("%w: %s", ErrLinkedFileSkipped, sourcePath)
+			case LinkPolicyFollow:
+				// A plain symlink can be followed and its target replaced atomically,
+				// hard links can only be kept by writing into the shared inode
+				if linkInfo.LinkCount > 1 {
+					inPlace = true
+				} else {
+					finalPath = linkInfo.ResolvedPath
+				}
+			case LinkPolicyInPlace:
+				inPlace = true
+			}
+		}
+	}
+
+	// Naming templates may place the output in sub folders that don't exist yet
+	if err := os.MkdirAll(filepath.Dir(finalPath), os.ModePerm); err != nil {
+		return ConvertStats{}, fmt.Errorf("failed to create target folder: %w", err)
+	}
+
+	// Create the temporary file in the destination directory to ensure the final
+	// rename stays on the same filesystem and is therefore atomic
+	tempFile, err := os.CreateTemp(filepath.Dir(finalPath), ".jpegli-*.tmp")
+	if err != nil {
+		return ConvertStats{}, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tempFile.Close() // Close immediately, we just need the path
+	tempPath := tempFile.Name()
+	replaced := false
+	defer func() {
+		if !replaced {
+			os.Remove(tempPath)
+		}
+	}()
+
+	var exifInfo imagemeta.ExifInfo
+	if opts.NormalizeOrientation || thumbnailPolicy == ThumbnailRegenerate {
+		if exifInfo, err = imagemeta.ReadExif(sourcePath); err != nil {
+			warnings = append(warnings, fmt.Sprintf("exif of %s not readable: %s", sourcePath, err))
+		}
+	}
+
+	// cjpegli encodes RGB, the CMYK profile of the source must not be copied to it
+	if toRGB {
+		rgb, err := rgbCopy(encoderInput, filepath.Dir(finalPath))
+		if err != nil {
+			return ConvertStats{}, fmt.Errorf("failed to convert %s to RGB: %w", sourcePath, err)
+		}
+		defer os.Remove(rgb)
+		encoderInput = rgb
+	}
+
+	// Compose transparent pixels onto the background, instead of leaving it to the encoder
+	flattenedOnto := ""
+	if transparent != nil {
+		flat, err := flatten(transparent, background, filepath.Dir(finalPath))
+		if err != nil {
+			return ConvertStats{}, fmt.Errorf("failed to flatten transparency: %w", err)
+		}
+		defer os.Remove(flat)
+		encoderInput = flat
+		flattenedOnto = fmt.Sprintf("#%02x%02x%02x", background.R, background.G, background.B)
+	}
+
+	// Rotate the pixels before encoding, cjpegli then encodes an upright image
+	oriented := 0
+	if opts.NormalizeOrientation && exifInfo.Orientation > 1 && exifInfo.Orientation <= 8 {
+		if len(multiPicture.Images) > 0 && multiPicturePolicy != MultiPictureWarn {
+			warnings = append(warnings, "orientation not applied, the secondary images would not match the rotated image")
+		} else if upright, err := orientedCopy(encoderInput, filepath.Dir(finalPath), exifInfo.Orientation); err != nil {
+			warnings = append(warnings, fmt.Sprintf("orientation not applied: %s", err))
+		} else {
+			defer os.Remove(upright)
+			encoderInput = upright
+			oriented = exifInfo.Orientation
+		}
+	}
+
+	// Gray content in a color image is detected on the final pixels
+	var gray string
+	if opts.DetectGrayscale {
+		tolerance := opts.GrayscaleTolerance
+		if tolerance <= 0 {
+			tolerance = defaultGrayscaleTolerance
+		}
+		if gray, err = grayCopy(encoderInput, filepath.Dir(finalPath), tolerance); err != nil {
+			warnings = append(warnings, fmt.Sprintf("grayscale detection failed: %s", err))
+		} else if gray != "" {
+			defer os.Remove(gray)
 		}
-		tempFile.Close() // Close immediately, we just need the path
-		actualTargetPath = tempFile.Name()
 	}
 
 	// Use exec.Command to run cjpegli with the provided distance parameter
-	cmd := exec.Command(tools.Cjpegli, sourcePath, actualTargetPath, "-d", fmt.Sprintf("%.1f", distanceValue))
+	encoderOptions := encoderArgs(distanceValue)
+	cmd := exec.Command(tools.Cjpegli, append([]string{encoderInput, tempPath}, encoderOptions...)...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return ConvertStats{}, fmt.Errorf("cjpegli execution failed: %w\nOutput: %s", err, output)
 	}
 
-	// Step 2: Copy metadata from source to target using ExifTool
-	copyMetadataArgs := withExiftoolConfig(tools, "-overwrite_original", "-TagsFromFile", sourcePath, actualTargetPath)
+	// The color encoding is kept unless the single channel one is smaller, so
+	// the savings reported are measured, not estimated
+	var grayscaleSaved int64
+	if gray != "" {
+		if grayscaleSaved, err = encodeGray(tools, gray, tempPath, encoderOptions); err != nil {
+			warnings = append(warnings, fmt.Sprintf("grayscale encoding failed, kept color: %s", err))
+		} else if grayscaleSaved > 0 {
+			encoderInput = gray
+		}
+	}
+
+	if oriented != 0 && exifInfo.Thumbnail && thumbnailPolicy == ThumbnailKeep {
+		warnings = append(warnings, "the EXIF thumbnail is not rotated, set the thumbnail policy to regenerate or remove")
+	}
+
+	// The thumbnail is made from the encoded pixels, only where the source has
+	// one and the metadata policy keeps it
+	var thumbnailPath string
+	if thumbnailPolicy == ThumbnailRegenerate && exifInfo.Thumbnail && !metadata.removes(thumbnailTag) {
+		if thumbnailPath, err = writeThumbnail(tempPath, filepath.Dir(finalPath)); err != nil {
+			warnings = append(warnings, fmt.Sprintf("thumbnail not regenerated: %s", err))
+		} else {
+			defer os.Remove(thumbnailPath)
+		}
+	}
+
+	// Write the metadata of PNG, GIF and JPEG XL sources natively into the output,
+	// exiftool then copies it from the output itself, so the metadata policy applies
+	nativeMetadata, nativeWarnings := writeNativeMetadata(sourcePath, tempPath)
+	warnings = append(warnings, nativeWarnings...)
+
+	// Step 2: Copy metadata from source to target using ExifTool, filtered by the metadata policy,
+	// merge sidecars and set the configured tags, all in one exiftool run
+	copyMetadataArgs := []string{"-overwrite_original"}
+	copyMetadataArgs = append(copyMetadataArgs, metadata.copyArgs(sourcePath, true)...)
+	if nativeMetadata {
+		copyMetadataArgs = append(copyMetadataArgs, metadata.copyArgs("@", false)...)
+	}
+	if sidecarPolicy == SidecarMerge {
+		copyMetadataArgs = append(copyMetadataArgs, sidecarArgs(metadata, sidecars)...)
+	}
+	copyMetadataArgs = append(copyMetadataArgs, tagArgs(sourcePath, injectedTags(opts))...)
+	if oriented != 0 {
+		copyMetadataArgs = append(copyMetadataArgs, "-Orientation#=1")
+	}
+	// The color profile of the source does not describe a single channel output
+	if toRGB || grayscaleSaved > 0 {
+		copyMetadataArgs = append(copyMetadataArgs, "-ICC_Profile=")
+	}
+	switch {
+	case thumbnailPath != "":
+		copyMetadataArgs = append(copyMetadataArgs, "-ThumbnailImage<="+thumbnailPath)
+	case thumbnailPolicy == ThumbnailRemove:
+		copyMetadataArgs = append(copyMetadataArgs, "-ThumbnailImage=")
+	}
+	copyMetadataArgs = withExiftoolConfig(tools, append(copyMetadataArgs, tempPath)...)
 	cmd = exec.Command(tools.Exiftool, copyMetadataArgs...)
 	output, err = cmd.CombinedOutput()
 	if err != nil {
-		// Clean up temporary file if it exists
-		if overrideOriginal {
-			os.Remove(actualTargetPath)
-		}
 		return ConvertStats{}, fmt.Errorf("exiftool execution failed: %w\nOutput: %s", err, output)
 	}
 
-	// Step 3: If overrideOriginal is true and both tools succeeded, replace the original file
-	if overrideOriginal {
-		// Both cjpegli and exiftool have succeeded, now replace the original
-		err = os.Rename(actualTargetPath, sourcePath)
+	// Step 3: Mark the temporary file as optimized and record its provenance, so the marker is part of the atomic replacement
+	originalHash, err := FileHash(sourcePath)
+	if err != nil {
+		return ConvertStats{}, fmt.Errorf("error hashing source file: %w", err)
+	}
+	provenance := Provenance{
+		OptimizedBy:      markerValue,
+		Distance:         distanceValue,
+		EncoderOptions:   strings.Join(encoderOptions, " "),
+		EncoderVersion:   encoderVersion(tools),
+		OriginalFileSize: sourceSize,
+		OriginalHash:     originalHash,
+		ProcessedAt:      time.Now(),
+		Profile:          opts.Profile,
+	}
+	if err := MarkAsOptimized(tools, tempPath, provenance); err != nil {
+		return ConvertStats{}, err
+	}
+
+	// Copy segments exiftool does not round-trip byte for byte, e.g. Photoshop
+	// clipping paths, after it has written everything else
+	transplanted, transplantWarnings := transplantSegments(sourcePath, tempPath, transplant)
+	warnings = append(warnings, transplantWarnings...)
+
+	// cjpegli encodes the primary image only, secondary images are appended
+	// after all exiftool runs, which would not keep them at the correct offsets
+	warnings = append(warnings, keepMultiPicture(tools, multiPicturePolicy, multiPicture, tempPath, encoderOptions)...)
+
+	var metadataDiff *MetadataDiff
+	if opts.VerifyMetadata {
+		// The ICC profile is removed above for RGB converted and single channel output
+		var dropped []string
+		if toRGB || grayscaleSaved > 0 {
+			dropped = append(dropped, iccProfile)
+		}
+		diff, err := compareMetadata(tools, metadata, dropped, sourcePath, tempPath)
 		if err != nil {
-			os.Remove(actualTargetPath) // Clean up temporary file
-			return ConvertStats{}, fmt.Errorf("failed to replace original file: %w", err)
+			warnings = append(warnings, fmt.Sprintf("metadata verification failed: %s", err))
+		} else {
+			metadataDiff = &diff
+			for _, lost := range diff.ImportantLost {
+				warnings = append(warnings, fmt.Sprintf("metadata lost: %s", lost))
+			}
 		}
-		// Update targetPath to sourcePath for stats calculation
-		actualTargetPath = sourcePath
 	}
 
-	// Step 4: Mark target file as optimized only after conversion and metadata copy succeeded.
-	markerErr := MarkAsOptimized(tools, actualTargetPath, markerValue)
-	if markerErr != nil {
-		return ConvertStats{}, markerErr
+	// Step 4: Carry over attributes, flush the temporary file to disk and verify
+	// it before it replaces anything
+	if err := preserveAttributes(opts, sourcePath, tempPath); err != nil {
+		return ConvertStats{}, err
+	}
+	if err := syncFile(tempPath); err != nil {
+		return ConvertStats{}, fmt.Errorf("failed to sync temporary file: %w", err)
+	}
+	if err := applyMode(opts, sourceInfo, tempPath); err != nil {
+		return ConvertStats{}, err
+	}
+	// A rotated image is compared with the encoder input, which has the new dimensions
+	if err := verifyOutput(encoderInput, tempPath); err != nil {
+		return ConvertStats{}, fmt.Errorf("output verification failed for %s: %w", sourcePath, err)
+	}
+	// Timestamps come last, reading the file for the verification may update its access time
+	if err := preserveTimes(opts, sourceInfo, tempPath); err != nil {
+		return ConvertStats{}, err
+	}
+
+	// Step 5: Atomically move the complete file into place and persist the directory entry,
+	// or write linked files in place to keep their links intact
+	if inPlace {
+		if err := writeInPlace(tempPath, finalPath); err != nil {
+			return ConvertStats{}, fmt.Errorf("failed to write target file in place: %w", err)
+		}
+		if err := preserveTimes(opts, sourceInfo, finalPath); err != nil {
+			return ConvertStats{}, err
+		}
+	} else {
+		if err := os.Rename(tempPath, finalPath); err != nil {
+			return ConvertStats{}, fmt.Errorf("failed to replace target file: %w", err)
+		}
+		replaced = true
+		if err := syncDir(filepath.Dir(finalPath)); err != nil {
+			return ConvertStats{}, fmt.Errorf("failed to sync target directory: %w", err)
+		}
 	}
 
-	// Step 5: Get file sizes and calculate statistics
-	targetInfo, err := os.Stat(actualTargetPath)
+	// Step 6: Get file sizes and calculate statistics
+	targetInfo, err := os.Stat(finalPath)
 	if err != nil {
 		return ConvertStats{}, fmt.Errorf("error getting target file info: %w", err)
 	}
@@ -114,33 +513,31 @@ func Convert(tools types.ExecutablePaths, distance float64, overrideOriginal boo
 	// Calculate ratio (target size / source size)
 	ratio := float64(targetSize) / float64(sourceSize)
 
-	return ConvertStats{
-		FileSizeRatio: ratio,
-		SourceSize:    sourceSize,
-		TargetSize:    targetSize,
-		SavedSize:     sourceSize - targetSize,
-	}, nil
-}
-
-func ReadOptimizedBy(tools types.ExecutablePaths, sourcePath string) (string, error) {
-	if tools.Exiftool == "" {
-		return "", fmt.Errorf("exiftool path is empty")
-	}
-	if tools.ExiftoolConfig == "" {
-		return "", fmt.Errorf("exiftool config path is empty")
+	if sidecarPolicy == SidecarCopy {
+		warnings = append(warnings, copySidecars(sidecars, sourcePath, finalPath)...)
 	}
 
-	// -q -q for quiet mode to suppress warnings
-	args := withExiftoolConfig(tools, "-s3", "-q", "-q", "-"+OptimizedByTag, sourcePath)
-	cmd := exec.Command(tools.Exiftool, args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("exiftool read marker failed: %w\nOutput: %s", err, output)
-	}
-	return strings.TrimSpace(string(output)), nil
+	return ConvertStats{
+		FileSizeRatio:  ratio,
+		SourceSize:     sourceSize,
+		TargetSize:     targetSize,
+		SavedSize:      sourceSize - targetSize,
+		Sidecars:       sidecars,
+		FlattenedOnto:  flattenedOnto,
+		Oriented:       oriented,
+		SourceQuality:  sourceQuality,
+		Grayscale:      grayscaleSaved > 0,
+		GrayscaleSaved: grayscaleSaved,
+		Transplanted:   transplanted,
+		Provenance:     provenance,
+		MetadataDiff:   metadataDiff,
+		Metadata:       metadata.String(),
+		Warnings:       warnings,
+	}, nil
 }
 
-func MarkAsOptimized(tools types.ExecutablePaths, targetPath, markerValue string) error {
+// MarkAsOptimized writes OptimizedByTag and the provenance tags to targetPath.
+func MarkAsOptimized(tools types.ExecutablePaths, targetPath string, provenance Provenance) error {
 	if tools.Exiftool == "" {
 		return fmt.Errorf("exiftool path is empty")
 	}
@@ -148,8 +545,8 @@ func MarkAsOptimized(tools types.ExecutablePaths, targetPath, markerValue string
 		return fmt.Errorf("exiftool config path is empty")
 	}
 
-	tagAssignment := fmt.Sprintf("-%s=%s", OptimizedByTag, markerValue)
-	args := withExiftoolConfig(tools, "-overwrite_original", tagAssignment, targetPath)
+	args := append([]string{"-overwrite_original"}, provenance.args()...)
+	args = withExiftoolConfig(tools, append(args, targetPath)...)
 	cmd := exec.Command(tools.Exiftool, args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -0,0 +1,95 @@
+package convert
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/dhcgn/jpegli-windows-explorer-extension/types"
+)
+
+// defaultGrayscaleTolerance allows for the chroma noise of scanners and of
+// JPEG compression in images that are meant to be gray.
+const defaultGrayscaleTolerance = 2
+
+// grayCopy decodes path and, if the color channels of every pixel differ by at
+// most tolerance levels (0 to 255), writes it as single channel PNG into dir.
+// cjpegli encodes such input as single component JPEG. It returns "" for color
+// images, images that are single channel already and formats Go cannot
+// decode. The caller removes the returned file.
+func grayCopy(path, dir string, tolerance int) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return "", nil
+	}
+	switch img.(type) {
+	case *image.Gray, *image.Gray16:
+		return "", nil
+	}
+	if !isGray(img, tolerance) {
+		return "", nil
+	}
+	bounds := img.Bounds()
+	gray := image.NewGray(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(gray, gray.Bounds(), img, bounds.Min, draw.Src)
+	return writeTempPNG(gray, dir)
+}
+
+// isGray reports whether the color channels of every pixel of img differ by
+// at most tolerance levels.
+func isGray(img image.Image, tolerance int) bool {
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			r, g, b := int(c.R), int(c.G), int(c.B)
+			if max(r, g, b)-min(r, g, b) > tolerance {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// encodeGray encodes the single channel image gray next to the color encoded
+// tempPath and replaces tempPath with it if it is smaller. It returns the
+// bytes saved over the color encoding, 0 if the color encoding is kept.
+func encodeGray(tools types.ExecutablePaths, gray, tempPath string, encoderOptions []string) (int64, error) {
+	grayFile, err := os.CreateTemp(filepath.Dir(tempPath), ".jpegli-*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	grayFile.Close()
+	grayPath := grayFile.Name()
+	defer os.Remove(grayPath)
+
+	cmd := exec.Command(tools.Cjpegli, append([]string{gray, grayPath}, encoderOptions...)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return 0, fmt.Errorf("cjpegli execution failed: %w\nOutput: %s", err, output)
+	}
+	colorInfo, err := os.Stat(tempPath)
+	if err != nil {
+		return 0, err
+	}
+	grayInfo, err := os.Stat(grayPath)
+	if err != nil {
+		return 0, err
+	}
+	saved := colorInfo.Size() - grayInfo.Size()
+	if saved <= 0 {
+		return 0, nil
+	}
+	if err := os.Rename(grayPath, tempPath); err != nil {
+		return 0, err
+	}
+	return saved, nil
+}
@@ -0,0 +1,67 @@
+package convert
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsGray(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = 100, 101, 102, 255
+	}
+	if !isGray(img, 2) {
+		t.Error("isGray() = false within tolerance, want true")
+	}
+	if isGray(img, 1) {
+		t.Error("isGray() = true beyond tolerance, want false")
+	}
+	img.Set(3, 3, color.NRGBA{R: 200, G: 100, B: 100, A: 255})
+	if isGray(img, 2) {
+		t.Error("isGray() = true with a colored pixel, want false")
+	}
+}
+
+func TestGrayCopy(t *testing.T) {
+	dir := t.TempDir()
+	grayContent := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			v := uint8(x * 30)
+			grayContent.Set(x, y, color.RGBA{R: v, G: v + 1, B: v, A: 255})
+		}
+	}
+	grayPNG := filepath.Join(dir, "scan.png")
+	writeImage(t, grayPNG, grayContent)
+	colorPNG := filepath.Join(dir, "color.png")
+	writeTestImage(t, colorPNG, 8, 8)
+	singleChannel := filepath.Join(dir, "single.png")
+	writeImage(t, singleChannel, image.NewGray(image.Rect(0, 0, 8, 8)))
+
+	path, err := grayCopy(grayPNG, dir, defaultGrayscaleTolerance)
+	if err != nil || path == "" {
+		t.Fatalf("grayCopy() = %q, %v, want a copy", path, err)
+	}
+	defer os.Remove(path)
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := img.(*image.Gray); !ok || img.Bounds() != grayContent.Bounds() {
+		t.Errorf("grayCopy() wrote %T %v, want *image.Gray %v", img, img.Bounds(), grayContent.Bounds())
+	}
+
+	for _, source := range []string{colorPNG, singleChannel, filepath.Join(dir, "missing.jxl")} {
+		if path, _ := grayCopy(source, dir, defaultGrayscaleTolerance); path != "" {
+			t.Errorf("grayCopy(%s) = %q, want no copy", filepath.Base(source), path)
+		}
+	}
+}
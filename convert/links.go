@@ -0,0 +1,59 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// LinkPolicy decides how OverrideOriginal treats symbolic links and files with
+// multiple hard links, which a rename over the source would break.
+type LinkPolicy string
+
+const (
+	// LinkPolicyFollow replaces the file a symlink points to and writes hard-linked files in place
+	LinkPolicyFollow LinkPolicy = "follow"
+	// LinkPolicyInPlace writes the new content into the existing file, keeping its inode
+	LinkPolicyInPlace LinkPolicy = "in_place"
+	// LinkPolicySkip leaves symlinks and hard-linked files untouched
+	LinkPolicySkip LinkPolicy = "skip"
+)
+
+// ErrLinkedFileSkipped is returned when a linked file is skipped due to LinkPolicySkip.
+var ErrLinkedFileSkipped = errors.New("skipped linked file")
+
+func (p LinkPolicy) normalize() (LinkPolicy, error) {
+	switch p {
+	case "":
+		return LinkPolicyFollow, nil
+	case LinkPolicyFollow, LinkPolicyInPlace, LinkPolicySkip:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown link policy: %q", p)
+}
+
+// writeInPlace copies the content of tempPath into the existing file at finalPath,
+// so all hard links and symlinks keep pointing to the new content. Unlike a rename
+// this is not atomic, which is why it is only used for linked files.
+func writeInPlace(tempPath, finalPath string) error {
+	src, err := os.Open(tempPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(finalPath, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
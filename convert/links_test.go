@@ -0,0 +1,37 @@
+package convert
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteInPlaceKeepsHardLinks(t *testing.T) {
+	dir := t.TempDir()
+	original := filepath.Join(dir, "original.jpg")
+	if err := os.WriteFile(original, []byte("old content"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	hardLink := filepath.Join(dir, "hardlink.jpg")
+	if err := os.Link(original, hardLink); err != nil {
+		t.Skipf("Hard links not supported: %v", err)
+	}
+	temp := filepath.Join(dir, "temp.tmp")
+	if err := os.WriteFile(temp, []byte("new"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if err := writeInPlace(temp, original); err != nil {
+		t.Fatalf("writeInPlace() error = %v", err)
+	}
+
+	for _, path := range []string{original, hardLink} {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", path, err)
+		}
+		if string(content) != "new" {
+			t.Errorf("Expected %s to contain %q, got %q", path, "new", content)
+		}
+	}
+}
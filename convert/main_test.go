@@ -0,0 +1,60 @@
+package convert
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+	"os"
+	"testing"
+
+	"github.com/dhcgn/jpegli-windows-explorer-extension/types"
+)
+
+// fakeCjpegliEnv makes the test binary act as cjpegli, see fakeCjpegli.
+const fakeCjpegliEnv = "CONVERT_TEST_FAKE_CJPEGLI"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(fakeCjpegliEnv) != "" {
+		if err := fakeCjpegli(os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// fakeCjpegli encodes "input output [options]" with image/jpeg at quality 50,
+// single channel input stays single channel as with cjpegli.
+func fakeCjpegli(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: cjpegli input output [options]")
+	}
+	in, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	img, _, err := image.Decode(in)
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(args[1])
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	return jpeg.Encode(out, img, &jpeg.Options{Quality: 50})
+}
+
+// fakeTools returns tools whose cjpegli is the test binary.
+func fakeTools(t *testing.T) types.ExecutablePaths {
+	t.Helper()
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(fakeCjpegliEnv, "1")
+	return types.ExecutablePaths{Cjpegli: executable}
+}
@@ -0,0 +1,103 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dhcgn/jpegli-windows-explorer-extension/types"
+)
+
+// FileMetadata holds the tags read from a source file before conversion.
+type FileMetadata struct {
+	// OptimizedBy is the processed marker, empty if the file was never optimized
+	OptimizedBy string
+	// Distance and Profile are the provenance tags of the marker,
+	// DistanceKnown is false for files marked without them
+	Distance      float64
+	DistanceKnown bool
+	Profile       string
+	// CaptureDate is EXIF DateTimeOriginal, zero if missing or invalid
+	CaptureDate time.Time
+	Camera      string
+	Lens        string
+}
+
+// WithProvenance returns m with the marker tags set to the values written by
+// MarkAsOptimized, which is the metadata of an output converted from m.
+func (m FileMetadata) WithProvenance(p Provenance) FileMetadata {
+	m.OptimizedBy = p.OptimizedBy
+	m.Distance = p.Distance
+	m.DistanceKnown = true
+	m.Profile = p.Profile
+	return m
+}
+
+const exiftoolDateFormat = "2006-01-02 15:04:05"
+
+// ReadFileMetadata reads the processed marker with its provenance and the capture information of
+// sourcePath with a single exiftool call.
+func ReadFileMetadata(tools types.ExecutablePaths, sourcePath string) (FileMetadata, error) {
+	if tools.Exiftool == "" {
+		return FileMetadata{}, fmt.Errorf("exiftool path is empty")
+	}
+	if tools.ExiftoolConfig == "" {
+		return FileMetadata{}, fmt.Errorf("exiftool config path is empty")
+	}
+
+	// -q -q for quiet mode to suppress warnings
+	args := withExiftoolConfig(tools, "-json", "-q", "-q", "-d", "%Y-%m-%d %H:%M:%S",
+		"-"+OptimizedByTag, "-"+DistanceTag, "-"+ProfileTag, "-DateTimeOriginal", "-Model", "-LensModel", sourcePath)
+	cmd := exec.Command(tools.Exiftool, args...)
+	output, err := cmd.Output()
+	if err != nil {
+		return FileMetadata{}, fmt.Errorf("exiftool read metadata failed: %w\nOutput: %s", err, output)
+	}
+	return parseFileMetadata(output)
+}
+
+func parseFileMetadata(output []byte) (FileMetadata, error) {
+	output = bytes.TrimSpace(output)
+	if len(output) == 0 {
+		return FileMetadata{}, nil
+	}
+
+	var results []map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	decoder.UseNumber()
+	if err := decoder.Decode(&results); err != nil {
+		return FileMetadata{}, fmt.Errorf("failed to parse exiftool output: %w", err)
+	}
+	if len(results) == 0 {
+		return FileMetadata{}, nil
+	}
+
+	tags := results[0]
+	metadata := FileMetadata{
+		OptimizedBy: tagString(tags, "OptimizedBy"),
+		Profile:     tagString(tags, "Profile"),
+		Camera:      tagString(tags, "Model"),
+		Lens:        tagString(tags, "LensModel"),
+	}
+	if distance, err := strconv.ParseFloat(tagString(tags, "Distance"), 64); err == nil {
+		metadata.Distance = distance
+		metadata.DistanceKnown = true
+	}
+	if date, err := time.Parse(exiftoolDateFormat, tagString(tags, "DateTimeOriginal")); err == nil {
+		metadata.CaptureDate = date
+	}
+	return metadata, nil
+}
+
+// tagString returns a tag value as trimmed string, exiftool emits numeric looking values as JSON numbers.
+func tagString(tags map[string]any, name string) string {
+	value, ok := tags[name]
+	if !ok || value == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(value))
+}
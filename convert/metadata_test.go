@@ -0,0 +1,62 @@
+package convert
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFileMetadata(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   FileMetadata
+	}{
+		{
+			name:   "all tags",
+			output: `[{"SourceFile":"a.jpg","OptimizedBy":"jpegli-windows-explorer-extension 1.0.0","DateTimeOriginal":"2023-07-14 18:02:11","Model":"NIKON Z 6_2","LensModel":"NIKKOR Z 24-70mm f/4 S"}]`,
+			want: FileMetadata{
+				OptimizedBy: "jpegli-windows-explorer-extension 1.0.0",
+				CaptureDate: time.Date(2023, 7, 14, 18, 2, 11, 0, time.UTC),
+				Camera:      "NIKON Z 6_2",
+				Lens:        "NIKKOR Z 24-70mm f/4 S",
+			},
+		},
+		{
+			name:   "provenance tags",
+			output: `[{"SourceFile":"a.jpg","OptimizedBy":"jpegli-windows-explorer-extension 1.1.0","Distance":0.5,"Profile":"web"}]`,
+			want: FileMetadata{
+				OptimizedBy:   "jpegli-windows-explorer-extension 1.1.0",
+				Distance:      0.5,
+				DistanceKnown: true,
+				Profile:       "web",
+			},
+		},
+		{
+			name:   "numeric model and invalid date",
+			output: `[{"SourceFile":"a.jpg","DateTimeOriginal":"0000:00:00 00:00:00","Model":850}]`,
+			want:   FileMetadata{Camera: "850"},
+		},
+		{
+			name:   "no tags",
+			output: `[{"SourceFile":"a.png"}]`,
+			want:   FileMetadata{},
+		},
+		{
+			name:   "empty output",
+			output: "",
+			want:   FileMetadata{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFileMetadata([]byte(tt.output))
+			if err != nil {
+				t.Fatalf("parseFileMetadata() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("parseFileMetadata() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/dhcgn/jpegli-windows-explorer-extension/types"
+)
+
+// MetadataDiff compares the tags of a source with those of its output.
+type MetadataDiff struct {
+	Preserved int
+	// Changed and Lost list the affected tags as "Group:Tag"
+	Changed []string
+	Lost    []string
+	// ImportantLost lists lost tag categories that matter for most users and
+	// were not removed on purpose by the metadata policy, e.g. "ICC profile"
+	ImportantLost []string
+}
+
+// String summarizes d for the per-file report.
+func (d MetadataDiff) String() string {
+	return fmt.Sprintf("%d preserved, %d changed, %d lost", d.Preserved, len(d.Changed), len(d.Lost))
+}
+
+// ignoredTagGroups hold tags describing the file or its encoding rather than
+// the image, they differ between source and output by design.
+var ignoredTagGroups = map[string]bool{
+	"ExifTool":   true,
+	"System":     true,
+	"File":       true,
+	"Composite":  true,
+	"JFIF":       true,
+	"XMP-jpegli": true,
+}
+
+// ignoredTagNames are encoding properties found in otherwise relevant groups.
+var ignoredTagNames = map[string]bool{
+	"ImageWidth":       true,
+	"ImageHeight":      true,
+	"BitDepth":         true,
+	"BitsPerSample":    true,
+	"ColorType":        true,
+	"Compression":      true,
+	"Filter":           true,
+	"Interlace":        true,
+	"ColorComponents":  true,
+	"YCbCrSubSampling": true,
+	"EncodingProcess":  true,
+}
+
+// importantTag is a category of tags whose loss is flagged, matched by
+// keyword against the tag name and group.
+type importantTag struct {
+	Name     string
+	Keywords []string
+}
+
+// iccProfile names the ICC profile category, which the conversion itself drops
+// for output the source profile does not describe.
+const iccProfile = "ICC profile"
+
+var importantTags = []importantTag{
+	{Name: iccProfile, Keywords: []string{"icc"}},
+	{Name: "orientation", Keywords: []string{"orientation"}},
+	{Name: "GPS location", Keywords: []string{"gps"}},
+	{Name: "copyright", Keywords: []string{"copyright", "rights"}},
+}
+
+func (t importantTag) matches(tag string) bool {
+	tag = strings.ToLower(tag)
+	for _, keyword := range t.Keywords {
+		if strings.Contains(tag, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareMetadata reads the tags of sourcePath and outputPath with a single
+// exiftool call and compares them. dropped names the important categories the
+// conversion removed on purpose, besides those of the metadata policy.
+func compareMetadata(tools types.ExecutablePaths, metadata metadataFilter, dropped []string, sourcePath, outputPath string) (MetadataDiff, error) {
+	// -G1 names tags by their specific group, e.g. IFD0:Copyright or XMP-dc:Rights
+	args := withExiftoolConfig(tools, "-json", "-G1", "-q", "-q", sourcePath, outputPath)
+	cmd := exec.Command(tools.Exiftool, args...)
+	output, err := cmd.Output()
+	if err != nil {
+		return MetadataDiff{}, fmt.Errorf("exiftool read tags failed: %w\nOutput: %s", err, output)
+	}
+	var results []map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	decoder.UseNumber()
+	if err := decoder.Decode(&results); err != nil {
+		return MetadataDiff{}, fmt.Errorf("failed to parse exiftool output: %w", err)
+	}
+	if len(results) != 2 {
+		return MetadataDiff{}, fmt.Errorf("exiftool returned tags for %d files, expected 2", len(results))
+	}
+	return diffTags(relevantTags(results[0]), relevantTags(results[1]), metadata, dropped), nil
+}
+
+// relevantTags returns the tags of an exiftool -G1 result that describe the
+// image, as "Group:Tag" to value.
+func relevantTags(result map[string]any) map[string]string {
+	tags := map[string]string{}
+	for key := range result {
+		group, name, ok := strings.Cut(key, ":")
+		if !ok || ignoredTagGroups[group] || ignoredTagNames[name] {
+			continue
+		}
+		tags[key] = tagString(result, key)
+	}
+	return tags
+}
+
+func diffTags(source, output map[string]string, metadata metadataFilter, dropped []string) MetadataDiff {
+	var diff MetadataDiff
+	for tag, value := range source {
+		outputValue, ok := output[tag]
+		switch {
+		case !ok:
+			diff.Lost = append(diff.Lost, tag)
+		case outputValue != value:
+			diff.Changed = append(diff.Changed, tag)
+		default:
+			diff.Preserved++
+		}
+	}
+	sort.Strings(diff.Lost)
+	sort.Strings(diff.Changed)
+
+	for _, important := range importantTags {
+		if metadata.removes(important) || slices.Contains(dropped, important.Name) {
+			continue
+		}
+		// A tag moved to another group, e.g. PNG text to XMP, still counts as kept
+		lost, kept := false, false
+		for tag := range source {
+			if _, ok := output[tag]; !ok && important.matches(tag) {
+				lost = true
+			}
+		}
+		for tag := range output {
+			if important.matches(tag) {
+				kept = true
+			}
+		}
+		if lost && !kept {
+			diff.ImportantLost = append(diff.ImportantLost, important.Name)
+		}
+	}
+	return diff
+}
+
+// removes reports whether the filter removes the tags of an important
+// category on purpose, so their loss is expected.
+func (f metadataFilter) removes(t importantTag) bool {
+	for _, tag := range f.Deny {
+		if t.matches(tag) {
+			return true
+		}
+	}
+	if len(f.Allow) == 0 {
+		return false
+	}
+	for _, tag := range f.Allow {
+		if t.matches(tag) || strings.EqualFold(tag, "all") || strings.HasSuffix(strings.ToLower(tag), ":all") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,125 @@
+package convert
+
+import (
+	"maps"
+	"reflect"
+	"testing"
+)
+
+func TestDiffTags(t *testing.T) {
+	source := relevantTags(map[string]any{
+		"SourceFile":             "a.jpg",
+		"File:FileSize":          "2.1 MB",
+		"IFD0:Make":              "NIKON",
+		"IFD0:Orientation":       "Rotate 90 CW",
+		"IFD0:Copyright":         "Jane Doe",
+		"GPS:GPSLatitude":        "52 deg 31' 0.00\"",
+		"ICC_Profile:ColorSpace": "RGB",
+		"ExifIFD:Software":       "DxO",
+		"JFIF:JFIFVersion":       "1.01",
+		"PNG:ImageWidth":         6048,
+	})
+	output := relevantTags(map[string]any{
+		"SourceFile":                 "a.jpegli.jpg",
+		"File:FileSize":              "0.9 MB",
+		"IFD0:Make":                  "NIKON",
+		"IFD0:Copyright":             "Jane Doe",
+		"ExifIFD:Software":           "jpegli",
+		"XMP-jpegli:OptimizedBy":     "app 1.0",
+		"ICC_Profile:ColorSpaceData": "RGB",
+	})
+	withoutICC := maps.Clone(output)
+	delete(withoutICC, "ICC_Profile:ColorSpaceData")
+
+	tests := []struct {
+		name    string
+		policy  MetadataPolicy
+		output  map[string]string
+		dropped []string
+		want    MetadataDiff
+	}{
+		{
+			name:   "keep all flags important losses",
+			policy: MetadataKeepAll,
+			want: MetadataDiff{
+				Preserved:     2,
+				Changed:       []string{"ExifIFD:Software"},
+				Lost:          []string{"GPS:GPSLatitude", "ICC_Profile:ColorSpace", "IFD0:Orientation"},
+				ImportantLost: []string{"orientation", "GPS location"},
+			},
+		},
+		{
+			name:   "privacy removes GPS on purpose",
+			policy: MetadataPrivacy,
+			want: MetadataDiff{
+				Preserved:     2,
+				Changed:       []string{"ExifIFD:Software"},
+				Lost:          []string{"GPS:GPSLatitude", "ICC_Profile:ColorSpace", "IFD0:Orientation"},
+				ImportantLost: []string{"orientation"},
+			},
+		},
+		{
+			name:   "ICC profile lost",
+			policy: MetadataPrivacy,
+			output: withoutICC,
+			want: MetadataDiff{
+				Preserved:     2,
+				Changed:       []string{"ExifIFD:Software"},
+				Lost:          []string{"GPS:GPSLatitude", "ICC_Profile:ColorSpace", "IFD0:Orientation"},
+				ImportantLost: []string{"ICC profile", "orientation"},
+			},
+		},
+		{
+			name:    "ICC profile dropped on purpose",
+			policy:  MetadataPrivacy,
+			output:  withoutICC,
+			dropped: []string{iccProfile},
+			want: MetadataDiff{
+				Preserved:     2,
+				Changed:       []string{"ExifIFD:Software"},
+				Lost:          []string{"GPS:GPSLatitude", "ICC_Profile:ColorSpace", "IFD0:Orientation"},
+				ImportantLost: []string{"orientation"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := newMetadataFilter(tt.policy, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tagsOut := output
+			if tt.output != nil {
+				tagsOut = tt.output
+			}
+			if got := diffTags(source, tagsOut, filter, tt.dropped); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffTags() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetadataFilterRemoves(t *testing.T) {
+	gps := importantTags[2]
+	tests := []struct {
+		name  string
+		allow []string
+		deny  []string
+		want  bool
+	}{
+		{"nothing filtered", nil, nil, false},
+		{"denied", nil, []string{"gps:all"}, true},
+		{"not in allow list", []string{"ICC_Profile"}, nil, true},
+		{"group in allow list", []string{"exif:all"}, nil, false},
+		{"allowed by name", []string{"GPSLatitude"}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := metadataFilter{Policy: MetadataCustom, Allow: tt.allow, Deny: tt.deny}
+			if got := filter.removes(gps); got != tt.want {
+				t.Errorf("removes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package convert
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MetadataPolicy decides which tags of the source are carried over to the output.
+type MetadataPolicy string
+
+const (
+	// MetadataKeepAll copies every tag exiftool can copy
+	MetadataKeepAll MetadataPolicy = "keep_all"
+	// MetadataPrivacy copies everything except location, serial numbers,
+	// owner names, maker notes and embedded thumbnails
+	MetadataPrivacy MetadataPolicy = "privacy"
+	// MetadataMinimal keeps only the ICC profile, orientation and copyright
+	MetadataMinimal MetadataPolicy = "minimal"
+	// MetadataCustom uses the configured allow and deny tag lists
+	MetadataCustom MetadataPolicy = "custom"
+)
+
+// privacyDenyTags are removed by MetadataPrivacy, in exiftool tag name syntax.
+var privacyDenyTags = []string{
+	"gps:all",
+	"xmp:gps*",
+	"*SerialNumber",
+	"OwnerName",
+	"CameraOwnerName",
+	"makernotes:all",
+	"ThumbnailImage",
+	"PreviewImage",
+}
+
+// minimalAllowTags are the only tags kept by MetadataMinimal.
+var minimalAllowTags = []string{
+	"ICC_Profile",
+	"Orientation",
+	"Copyright",
+	"XMP-dc:Rights",
+	"IPTC:CopyrightNotice",
+}
+
+// metadataFilter is the resolved form of a MetadataPolicy.
+type metadataFilter struct {
+	Policy MetadataPolicy
+	// Allow lists the only tags copied, empty copies all tags
+	Allow []string
+	// Deny lists tags removed after copying
+	Deny []string
+}
+
+func newMetadataFilter(policy MetadataPolicy, allow, deny []string) (metadataFilter, error) {
+	switch policy {
+	case "", MetadataKeepAll:
+		return metadataFilter{Policy: MetadataKeepAll}, nil
+	case MetadataPrivacy:
+		return metadataFilter{Policy: policy, Deny: privacyDenyTags}, nil
+	case MetadataMinimal:
+		return metadataFilter{Policy: policy, Allow: minimalAllowTags}, nil
+	case MetadataCustom:
+		return metadataFilter{Policy: policy, Allow: allow, Deny: deny}, nil
+	}
+	return metadataFilter{}, fmt.Errorf("unknown metadata policy: %q", policy)
+}
+
+// copyArgs returns the exiftool arguments copying the tags of sourcePath that
+// pass the filter. With reset, all existing tags of the target are removed
+// first, which is how an allow list also drops what the encoder wrote.
+func (f metadataFilter) copyArgs(sourcePath string, reset bool) []string {
+	var args []string
+	if reset && len(f.Allow) > 0 {
+		args = append(args, "-all=")
+	}
+	args = append(args, "-TagsFromFile", sourcePath)
+	for _, tag := range f.Allow {
+		args = append(args, "-"+tag)
+	}
+	// Assignments after -TagsFromFile are applied after copying
+	for _, tag := range f.Deny {
+		args = append(args, "-"+tag+"=")
+	}
+	return args
+}
+
+// String describes the filter for the per-file report.
+func (f metadataFilter) String() string {
+	var parts []string
+	if len(f.Allow) > 0 {
+		parts = append(parts, "only "+strings.Join(f.Allow, ", "))
+	}
+	if len(f.Deny) > 0 {
+		parts = append(parts, "without "+strings.Join(f.Deny, ", "))
+	}
+	if len(parts) == 0 {
+		return string(f.Policy)
+	}
+	return fmt.Sprintf("%s (%s)", f.Policy, strings.Join(parts, "; "))
+}
@@ -0,0 +1,73 @@
+package convert
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMetadataFilterCopyArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  MetadataPolicy
+		allow   []string
+		deny    []string
+		reset   bool
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "keep all copies everything",
+			policy: MetadataKeepAll,
+			reset:  true,
+			want:   []string{"-TagsFromFile", "source.jpg"},
+		},
+		{
+			name:   "empty policy is keep all",
+			policy: "",
+			reset:  true,
+			want:   []string{"-TagsFromFile", "source.jpg"},
+		},
+		{
+			name:   "privacy removes tags after copying",
+			policy: MetadataPrivacy,
+			reset:  true,
+			want: []string{"-TagsFromFile", "source.jpg", "-gps:all=", "-xmp:gps*=", "-*SerialNumber=", "-OwnerName=",
+				"-CameraOwnerName=", "-makernotes:all=", "-ThumbnailImage=", "-PreviewImage="},
+		},
+		{
+			name:   "minimal resets and copies allow list",
+			policy: MetadataMinimal,
+			reset:  true,
+			want: []string{"-all=", "-TagsFromFile", "source.jpg", "-ICC_Profile", "-Orientation", "-Copyright",
+				"-XMP-dc:Rights", "-IPTC:CopyrightNotice"},
+		},
+		{
+			name:   "custom without reset",
+			policy: MetadataCustom,
+			allow:  []string{"exif:all"},
+			deny:   []string{"Artist"},
+			reset:  false,
+			want:   []string{"-TagsFromFile", "source.jpg", "-exif:all", "-Artist="},
+		},
+		{
+			name:    "unknown policy",
+			policy:  "strip_everything",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := newMetadataFilter(tt.policy, tt.allow, tt.deny)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newMetadataFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := filter.copyArgs("source.jpg", tt.reset); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("copyArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
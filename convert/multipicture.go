@@ -0,0 +1,138 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/dhcgn/jpegli-windows-explorer-extension/imagemeta"
+	"github.com/dhcgn/jpegli-windows-explorer-extension/types"
+)
+
+// MultiPicturePolicy decides what happens to the secondary images of a JPEG
+// (MPF images, Ultra HDR gain maps, depth maps), which cjpegli does not encode.
+type MultiPicturePolicy string
+
+const (
+	// MultiPictureWarn converts the primary image only and reports the lost images
+	MultiPictureWarn MultiPicturePolicy = "warn"
+	// MultiPictureSkip leaves JPEGs with secondary images untouched
+	MultiPictureSkip MultiPicturePolicy = "skip"
+	// MultiPictureReattach appends the secondary images unchanged to the output
+	MultiPictureReattach MultiPicturePolicy = "reattach"
+	// MultiPictureReencode re-encodes the secondary images with the same distance
+	// and appends them to the output
+	MultiPictureReencode MultiPicturePolicy = "reencode"
+)
+
+// ErrMultiPictureSkipped is returned when a file is skipped due to MultiPictureSkip.
+var ErrMultiPictureSkipped = errors.New("skipped file with secondary images")
+
+func (p MultiPicturePolicy) normalize() (MultiPicturePolicy, error) {
+	switch p {
+	case "":
+		return MultiPictureWarn, nil
+	case MultiPictureWarn, MultiPictureSkip, MultiPictureReattach, MultiPictureReencode:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown multi picture policy: %q", p)
+}
+
+// checkMultiPicture reads the secondary images of the source before anything
+// is written and returns ErrMultiPictureSkipped for files MultiPictureSkip
+// leaves untouched. A JPEG that can't be parsed, e.g. without EOI, is
+// converted as single image with a warning, cjpegli reports it if it is broken.
+func checkMultiPicture(policy MultiPicturePolicy, sourcePath string) (imagemeta.MultiPicture, []string, error) {
+	mp, err := imagemeta.ReadMultiPicture(sourcePath)
+	if err != nil {
+		return imagemeta.MultiPicture{}, []string{fmt.Sprintf("secondary images not readable, converted as single image: %s", err)}, nil
+	}
+	if !mp.Empty() && policy == MultiPictureSkip {
+		return mp, nil, fmt.Errorf("%w (%s): %s", ErrMultiPictureSkipped, strings.Join(mp.Kinds(), ", "), sourcePath)
+	}
+	return mp, nil, nil
+}
+
+// keepMultiPicture handles the secondary images of the source after the
+// encoded JPEG at targetPath got all its metadata: MultiPictureWarn reports
+// them as lost, the other policies attach them.
+func keepMultiPicture(tools types.ExecutablePaths, policy MultiPicturePolicy, mp imagemeta.MultiPicture, targetPath string, encoderOptions []string) []string {
+	switch {
+	case mp.Empty():
+		return nil
+	case policy != MultiPictureWarn:
+		return attachMultiPicture(tools, policy, mp, targetPath, encoderOptions)
+	case len(mp.Images) > 0:
+		return []string{fmt.Sprintf("secondary images lost: %s", strings.Join(mp.Kinds(), ", "))}
+	}
+	return nil
+}
+
+// attachMultiPicture appends the secondary images of the source to the encoded
+// JPEG at targetPath, re-encoded with encoderOptions for MultiPictureReencode.
+// Problems are returned as warnings, the primary image is valid either way.
+func attachMultiPicture(tools types.ExecutablePaths, policy MultiPicturePolicy, mp imagemeta.MultiPicture, targetPath string, encoderOptions []string) []string {
+	var warnings []string
+	if policy == MultiPictureReencode {
+		// The images are shared with the caller's copy of mp
+		mp.Images = append([]imagemeta.SecondaryImage(nil), mp.Images...)
+		for i, image := range mp.Images {
+			encoded, err := reencodeImage(tools, image.Data, filepath.Dir(targetPath), encoderOptions)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s not re-encoded, copied unchanged: %s", image.Kind, err))
+				continue
+			}
+			// A gain map is small already, only keep the result if it helps.
+			// WriteMultiPicture updates the lengths in MPF and XMP to it
+			if len(encoded) < len(image.Data) {
+				mp.Images[i].Data = encoded
+			}
+		}
+	}
+	if mp.EmbeddedDepthMap {
+		warnings = append(warnings, "depth map in XMP is only kept if the metadata policy keeps XMP")
+	}
+	if err := imagemeta.WriteMultiPicture(targetPath, mp); err != nil {
+		return append(warnings, fmt.Sprintf("secondary images lost (%s): %s", strings.Join(mp.Kinds(), ", "), err))
+	}
+
+	// The primary image describes its gain map and depth map in XMP, which the
+	// metadata policy may have removed
+	attached, err := imagemeta.ReadMultiPicture(targetPath)
+	if err == nil && strings.Join(attached.Kinds(), ",") != strings.Join(mp.Kinds(), ",") {
+		warnings = append(warnings, fmt.Sprintf("secondary images attached, but their XMP description was not kept: %s", strings.Join(mp.Kinds(), ", ")))
+	}
+	return warnings
+}
+
+// reencodeImage encodes a secondary JPEG with cjpegli and restores its APPn
+// segments, e.g. the gain map parameters of Ultra HDR.
+func reencodeImage(tools types.ExecutablePaths, data []byte, dir string, encoderOptions []string) ([]byte, error) {
+	input, err := os.CreateTemp(dir, ".jpegli-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(input.Name())
+	if _, err := input.Write(data); err != nil {
+		input.Close()
+		return nil, err
+	}
+	if err := input.Close(); err != nil {
+		return nil, err
+	}
+	output := input.Name() + ".jpg"
+	defer os.Remove(output)
+
+	cmd := exec.Command(tools.Cjpegli, append([]string{input.Name(), output}, encoderOptions...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("cjpegli execution failed: %w\nOutput: %s", err, out)
+	}
+	encoded, err := os.ReadFile(output)
+	if err != nil {
+		return nil, err
+	}
+	return imagemeta.CopyAppSegments(encoded, data)
+}
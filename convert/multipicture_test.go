@@ -0,0 +1,195 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dhcgn/jpegli-windows-explorer-extension/imagemeta"
+	"github.com/dhcgn/jpegli-windows-explorer-extension/types"
+)
+
+const testContainerXMP = `<x:xmpmeta><rdf:RDF><rdf:Description xmlns:hdrgm="http://ns.adobe.com/hdr-gain-map/1.0/">
+<Container:Directory><rdf:Seq>
+<rdf:li><Container:Item Item:Semantic="Primary" Item:Mime="image/jpeg"/></rdf:li>
+<rdf:li><Container:Item Item:Semantic="GainMap" Item:Mime="image/jpeg" Item:Length="0"/></rdf:li>
+</rdf:Seq></Container:Directory></rdf:Description></rdf:RDF></x:xmpmeta>`
+
+const testGainMapXMP = `<x:xmpmeta><rdf:RDF><rdf:Description hdrgm:Version="1.0"/></rdf:RDF></x:xmpmeta>`
+
+// testMPF builds an MPF segment payload with a primary and a second entry,
+// AttachMultiPicture fills in sizes and offsets.
+func testMPF() []byte {
+	le := binary.LittleEndian
+	tiff := le.AppendUint32([]byte("II\x2a\x00"), 8)
+	tiff = le.AppendUint16(tiff, 1)
+	tiff = le.AppendUint16(le.AppendUint16(tiff, 0xb002), 7)
+	tiff = le.AppendUint32(le.AppendUint32(tiff, 32), uint32(len(tiff)+12))
+	tiff = le.AppendUint32(tiff, 0)
+	tiff = append(tiff, make([]byte, 32)...)
+	le.PutUint32(tiff[len(tiff)-32:], 0x20030000)
+	return append([]byte("MPF\x00"), tiff...)
+}
+
+// encodeNoise returns a JPEG of noisy pixels, which re-encodes smaller at a
+// lower quality.
+func encodeNoise(t *testing.T, quality int, xmp string) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 64, 64))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7919 % 251)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatal(err)
+	}
+	if xmp == "" {
+		return buf.Bytes()
+	}
+	data, _, err := imagemeta.InsertJPEG(buf.Bytes(), imagemeta.Metadata{XMP: []byte(xmp)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// writeUltraHDR writes a JPEG with MPF segment, XMP container directory and
+// a gain map and returns the gain map.
+func writeUltraHDR(t *testing.T, path string) []byte {
+	t.Helper()
+	gainMap := encodeNoise(t, 100, testGainMapXMP)
+	primary := encodeNoise(t, 90, testContainerXMP)
+	data, err := imagemeta.AttachMultiPicture(primary, imagemeta.MultiPicture{MPF: testMPF(), Images: []imagemeta.SecondaryImage{{Data: gainMap}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return gainMap
+}
+
+func TestCheckMultiPicture(t *testing.T) {
+	dir := t.TempDir()
+	ultraHDR := filepath.Join(dir, "ultrahdr.jpg")
+	writeUltraHDR(t, ultraHDR)
+	plain := filepath.Join(dir, "plain.jpg")
+	writeTestImage(t, plain, 8, 8)
+	truncated := filepath.Join(dir, "truncated.jpg")
+	data, err := os.ReadFile(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(truncated, data[:len(data)-2], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		policy       MultiPicturePolicy
+		path         string
+		wantKinds    string
+		wantWarnings int
+		wantSkip     bool
+	}{
+		{name: "plain jpeg", policy: MultiPictureSkip, path: plain},
+		{name: "gain map skipped", policy: MultiPictureSkip, path: ultraHDR, wantKinds: "gain map", wantSkip: true},
+		{name: "gain map warned later", policy: MultiPictureWarn, path: ultraHDR, wantKinds: "gain map"},
+		{name: "missing EOI converted as single image", policy: MultiPictureSkip, path: truncated, wantWarnings: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mp, warnings, err := checkMultiPicture(tt.policy, tt.path)
+			if errors.Is(err, ErrMultiPictureSkipped) != tt.wantSkip || (err != nil && !tt.wantSkip) {
+				t.Fatalf("checkMultiPicture() error = %v, want skip %v", err, tt.wantSkip)
+			}
+			if got := strings.Join(mp.Kinds(), ", "); got != tt.wantKinds || len(warnings) != tt.wantWarnings {
+				t.Errorf("checkMultiPicture() = %q, %q, want %q and %d warning(s)", got, warnings, tt.wantKinds, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestKeepMultiPicture(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "ultrahdr.jpg")
+	gainMap := writeUltraHDR(t, source)
+	mp, err := imagemeta.ReadMultiPicture(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		// tools is nil for a missing cjpegli
+		tools        func(t *testing.T) types.ExecutablePaths
+		policy       MultiPicturePolicy
+		keepXMP      bool
+		wantWarning  string
+		wantAttached bool
+		wantReencode bool
+	}{
+		{name: "warn", policy: MultiPictureWarn, keepXMP: true, wantWarning: "secondary images lost: gain map"},
+		{name: "reattach", policy: MultiPictureReattach, keepXMP: true, wantAttached: true},
+		{name: "reattach without XMP", policy: MultiPictureReattach, wantWarning: "XMP description was not kept", wantAttached: true},
+		{name: "reencode", tools: fakeTools, policy: MultiPictureReencode, keepXMP: true, wantAttached: true, wantReencode: true},
+		{name: "reencode without cjpegli", policy: MultiPictureReencode, keepXMP: true, wantWarning: "gain map not re-encoded, copied unchanged", wantAttached: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tools := types.ExecutablePaths{Cjpegli: filepath.Join(dir, "missing-cjpegli")}
+			if tt.tools != nil {
+				tools = tt.tools(t)
+			}
+			xmp := ""
+			if tt.keepXMP {
+				xmp = testContainerXMP
+			}
+			target := filepath.Join(dir, "out.jpg")
+			if err := os.WriteFile(target, encodeNoise(t, 50, xmp), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			warnings := keepMultiPicture(tools, tt.policy, mp, target, encoderArgs(1.0))
+			if got := strings.Join(warnings, "; "); !strings.Contains(got, tt.wantWarning) || (tt.wantWarning == "" && got != "") {
+				t.Errorf("keepMultiPicture() warnings = %q, want %q", got, tt.wantWarning)
+			}
+
+			attached, err := imagemeta.ReadMultiPicture(target)
+			if err != nil {
+				t.Fatalf("ReadMultiPicture() error = %v", err)
+			}
+			if !tt.wantAttached {
+				if !attached.Empty() {
+					t.Errorf("secondary images attached with %s", tt.policy)
+				}
+				return
+			}
+			if len(attached.Images) != 1 || attached.MPF == nil {
+				t.Fatalf("attached %d image(s), MPF %v, want the gain map with MPF", len(attached.Images), attached.MPF != nil)
+			}
+			image := attached.Images[0].Data
+			if reencoded := !bytes.Equal(image, gainMap); reencoded != tt.wantReencode {
+				t.Errorf("gain map re-encoded = %v, want %v", reencoded, tt.wantReencode)
+			}
+			// The gain map keeps its own XMP, and the directory its length
+			if !bytes.Contains(image, []byte(`hdrgm:Version="1.0"`)) {
+				t.Error("gain map lost its XMP")
+			}
+			output, err := os.ReadFile(target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if length := fmt.Sprintf(`Item:Length="%d"`, len(image)); tt.keepXMP && !bytes.Contains(output, []byte(length)) {
+				t.Errorf("XMP container directory does not list %s", length)
+			}
+		})
+	}
+}
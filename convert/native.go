@@ -0,0 +1,36 @@
+package convert
+
+import (
+	"fmt"
+
+	"github.com/dhcgn/jpegli-windows-explorer-extension/imagemeta"
+)
+
+// writeNativeMetadata writes the EXIF, XMP, ICC and comments of a PNG, GIF or
+// JPEG XL source into the encoded JPEG at targetPath. It reports whether
+// anything was written; problems are returned as warnings, the conversion
+// continues with what exiftool copies by itself.
+func writeNativeMetadata(sourcePath, targetPath string) (bool, []string) {
+	native, err := imagemeta.Read(sourcePath)
+	warnings := prefixed(native.Warnings)
+	if err != nil {
+		return false, append(warnings, fmt.Sprintf("metadata of %s not readable: %s", sourcePath, err))
+	}
+	if native.Empty() {
+		return false, warnings
+	}
+	writeWarnings, err := imagemeta.WriteJPEG(targetPath, native)
+	warnings = append(warnings, prefixed(writeWarnings)...)
+	if err != nil {
+		return false, append(warnings, fmt.Sprintf("metadata of %s not written: %s", sourcePath, err))
+	}
+	return true, warnings
+}
+
+func prefixed(warnings []string) []string {
+	out := make([]string, 0, len(warnings))
+	for _, warning := range warnings {
+		out = append(out, "metadata: "+warning)
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+package convert
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+)
+
+// orientedCopy decodes sourcePath, rotates and mirrors its pixels as the EXIF
+// orientation describes and writes them as PNG into dir, as cjpegli input.
+// The caller removes the returned file.
+func orientedCopy(sourcePath, dir string, orientation int) (string, error) {
+	src, err := os.Open(sourcePath)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	img, _, err := image.Decode(src)
+	if err != nil {
+		return "", fmt.Errorf("source not decodable: %w", err)
+	}
+	return writeTempPNG(orient(img, orientation), dir)
+}
+
+// orient returns img transformed so it displays upright with orientation 1.
+// Orientations 5 to 8 swap width and height.
+func orient(img image.Image, orientation int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	// target maps a source pixel to its position in the upright image
+	var target func(x, y int) (int, int)
+	switch orientation {
+	case 2: // mirrored horizontally
+		target = func(x, y int) (int, int) { return w - 1 - x, y }
+	case 3: // rotated by 180°
+		target = func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case 4: // mirrored vertically
+		target = func(x, y int) (int, int) { return x, h - 1 - y }
+	case 5: // transposed
+		target = func(x, y int) (int, int) { return y, x }
+	case 6: // needs 90° clockwise rotation
+		target = func(x, y int) (int, int) { return h - 1 - y, x }
+	case 7: // transversed
+		target = func(x, y int) (int, int) { return h - 1 - y, w - 1 - x }
+	case 8: // needs 90° counter-clockwise rotation
+		target = func(x, y int) (int, int) { return y, w - 1 - x }
+	default:
+		return img
+	}
+
+	size := image.Rect(0, 0, w, h)
+	if orientation >= 5 {
+		size = image.Rect(0, 0, h, w)
+	}
+	dst := newImageLike(img, size)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			tx, ty := target(x, y)
+			dst.Set(tx, ty, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// newImageLike returns an empty image with the component count and bit depth
+// of img, so the encoder sees the same kind of image as without rotation.
+func newImageLike(img image.Image, r image.Rectangle) draw.Image {
+	switch img.(type) {
+	case *image.Gray:
+		return image.NewGray(r)
+	case *image.Gray16:
+		return image.NewGray16(r)
+	case *image.RGBA64, *image.NRGBA64:
+		return image.NewNRGBA64(r)
+	}
+	return image.NewNRGBA(r)
+}
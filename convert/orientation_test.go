@@ -0,0 +1,43 @@
+package convert
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestOrient(t *testing.T) {
+	// A 3x2 image with a marked top-left pixel
+	img := image.NewGray(image.Rect(0, 0, 3, 2))
+	img.SetGray(0, 0, color.Gray{Y: 255})
+
+	tests := []struct {
+		orientation int
+		// Position of the marked pixel in the upright image
+		x, y          int
+		width, height int
+	}{
+		{orientation: 1, x: 0, y: 0, width: 3, height: 2},
+		{orientation: 2, x: 2, y: 0, width: 3, height: 2},
+		{orientation: 3, x: 2, y: 1, width: 3, height: 2},
+		{orientation: 4, x: 0, y: 1, width: 3, height: 2},
+		{orientation: 5, x: 0, y: 0, width: 2, height: 3},
+		{orientation: 6, x: 1, y: 0, width: 2, height: 3},
+		{orientation: 7, x: 1, y: 2, width: 2, height: 3},
+		{orientation: 8, x: 0, y: 2, width: 2, height: 3},
+	}
+	for _, tt := range tests {
+		got := orient(img, tt.orientation)
+		bounds := got.Bounds()
+		if bounds.Dx() != tt.width || bounds.Dy() != tt.height {
+			t.Errorf("orient(%d) size = %dx%d, want %dx%d", tt.orientation, bounds.Dx(), bounds.Dy(), tt.width, tt.height)
+			continue
+		}
+		if _, ok := got.(*image.Gray); !ok {
+			t.Errorf("orient(%d) = %T, want *image.Gray", tt.orientation, got)
+		}
+		if c := color.GrayModel.Convert(got.At(tt.x, tt.y)).(color.Gray); c.Y != 255 {
+			t.Errorf("orient(%d) marked pixel not at %d,%d", tt.orientation, tt.x, tt.y)
+		}
+	}
+}
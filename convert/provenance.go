@@ -0,0 +1,125 @@
+package convert
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dhcgn/jpegli-windows-explorer-extension/types"
+)
+
+// Provenance tags written next to OptimizedByTag, defined in the XMP-jpegli
+// namespace of install/exiftool-jpegli.config.
+const (
+	DistanceTag         = "XMP-jpegli:Distance"
+	EncoderOptionsTag   = "XMP-jpegli:EncoderOptions"
+	EncoderVersionTag   = "XMP-jpegli:EncoderVersion"
+	OriginalFileSizeTag = "XMP-jpegli:OriginalFileSize"
+	OriginalHashTag     = "XMP-jpegli:OriginalHash"
+	ProcessedAtTag      = "XMP-jpegli:ProcessedAt"
+	ProfileTag          = "XMP-jpegli:Profile"
+)
+
+// provenanceDateFormat is the exiftool date syntax used for ProcessedAtTag.
+const provenanceDateFormat = "2006:01:02 15:04:05-07:00"
+
+// Provenance records how an output was produced, so archives can be audited
+// and re-encoded later with better settings.
+type Provenance struct {
+	// OptimizedBy is the application name and version
+	OptimizedBy string
+	// Distance is the jpegli distance used
+	Distance float64
+	// EncoderOptions are the cjpegli arguments besides input and output
+	EncoderOptions string
+	// EncoderVersion is the version reported by cjpegli, empty if unknown
+	EncoderVersion string
+	// OriginalFileSize is the size of the source in bytes
+	OriginalFileSize int64
+	// OriginalHash is the content hash of the source, "sha256:<hex>"
+	OriginalHash string
+	// ProcessedAt is the time of the conversion
+	ProcessedAt time.Time
+	// Profile is the name of the configuration used
+	Profile string
+}
+
+// args returns the exiftool assignments writing p. Empty values are skipped,
+// so a file never carries a misleading zero value.
+func (p Provenance) args() []string {
+	args := []string{fmt.Sprintf("-%s=%s", OptimizedByTag, p.OptimizedBy)}
+	add := func(tag, value string) {
+		if value != "" {
+			args = append(args, fmt.Sprintf("-%s=%s", tag, value))
+		}
+	}
+	add(DistanceTag, strconv.FormatFloat(p.Distance, 'f', 1, 64))
+	add(EncoderOptionsTag, p.EncoderOptions)
+	add(EncoderVersionTag, p.EncoderVersion)
+	if p.OriginalFileSize > 0 {
+		add(OriginalFileSizeTag, strconv.FormatInt(p.OriginalFileSize, 10))
+	}
+	add(OriginalHashTag, p.OriginalHash)
+	if !p.ProcessedAt.IsZero() {
+		add(ProcessedAtTag, p.ProcessedAt.Format(provenanceDateFormat))
+	}
+	add(ProfileTag, p.Profile)
+	return args
+}
+
+// encoderArgs returns the cjpegli options for distance.
+func encoderArgs(distance float64) []string {
+	return []string{"-d", strconv.FormatFloat(distance, 'f', 1, 64)}
+}
+
+// FileHash returns the sha256 of the file at path as "sha256:<hex>".
+func FileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// encoderVersions caches cjpegli versions by executable path, the version is
+// queried once per run instead of once per file.
+var encoderVersions sync.Map
+
+// encoderVersion returns the version reported by cjpegli --version, or an
+// empty string if it cannot be determined.
+func encoderVersion(tools types.ExecutablePaths) string {
+	if v, ok := encoderVersions.Load(tools.Cjpegli); ok {
+		return v.(string)
+	}
+	output, _ := exec.Command(tools.Cjpegli, "--version").CombinedOutput()
+	version := parseEncoderVersion(string(output))
+	encoderVersions.Store(tools.Cjpegli, version)
+	return version
+}
+
+var encoderVersionPattern = regexp.MustCompile(`\bv?\d+\.\d+(\.\d+)?\b`)
+
+// parseEncoderVersion returns the first line of the version output naming a
+// version, e.g. "cjpegli v0.11.1 794a5dcf [AVX2,SSE4,SSE2]".
+func parseEncoderVersion(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if encoderVersionPattern.MatchString(line) {
+			return line
+		}
+	}
+	return ""
+}
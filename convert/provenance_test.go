@@ -0,0 +1,87 @@
+package convert
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProvenanceArgs(t *testing.T) {
+	processedAt := time.Date(2024, 5, 17, 10, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+	tests := []struct {
+		name       string
+		provenance Provenance
+		want       []string
+	}{
+		{
+			name: "all values",
+			provenance: Provenance{
+				OptimizedBy:      "jpegli-windows-explorer-extension 1.2.3",
+				Distance:         0.5,
+				EncoderOptions:   "-d 0.5",
+				EncoderVersion:   "cjpegli v0.11.1",
+				OriginalFileSize: 123456,
+				OriginalHash:     "sha256:abc",
+				ProcessedAt:      processedAt,
+				Profile:          "web",
+			},
+			want: []string{
+				"-XMP-jpegli:OptimizedBy=jpegli-windows-explorer-extension 1.2.3",
+				"-XMP-jpegli:Distance=0.5",
+				"-XMP-jpegli:EncoderOptions=-d 0.5",
+				"-XMP-jpegli:EncoderVersion=cjpegli v0.11.1",
+				"-XMP-jpegli:OriginalFileSize=123456",
+				"-XMP-jpegli:OriginalHash=sha256:abc",
+				"-XMP-jpegli:ProcessedAt=2024:05:17 10:30:00+02:00",
+				"-XMP-jpegli:Profile=web",
+			},
+		},
+		{
+			name:       "unknown values are skipped",
+			provenance: Provenance{OptimizedBy: "app 1.0", Distance: 1},
+			want:       []string{"-XMP-jpegli:OptimizedBy=app 1.0", "-XMP-jpegli:Distance=1.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.provenance.args(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("args() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEncoderVersion(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"cjpegli v0.11.1 794a5dcf [AVX2,SSE4,SSE2]\n", "cjpegli v0.11.1 794a5dcf [AVX2,SSE4,SSE2]"},
+		{"\r\ncjpegli 0.12.0\r\n", "cjpegli 0.12.0"},
+		{"Unknown argument: --version\nUsage: cjpegli INPUT OUTPUT", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := parseEncoderVersion(tt.output); got != tt.want {
+			t.Errorf("parseEncoderVersion(%q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestFileHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "source.jpg")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := FileHash(path)
+	if err != nil {
+		t.Fatalf("FileHash() error = %v", err)
+	}
+	want := "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if got != want {
+		t.Errorf("FileHash() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,50 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/dhcgn/jpegli-windows-explorer-extension/imagemeta"
+)
+
+// ErrLowQualitySkipped is returned for JPEG sources below Options.MinSourceQuality.
+var ErrLowQualitySkipped = errors.New("skipped low quality source")
+
+// checkSourceQuality estimates the quality of a JPEG source from its
+// quantization tables, before anything is written. It returns
+// ErrLowQualitySkipped for sources below minQuality, re-encoding them only
+// adds generation loss. With matchQuality the returned distance is lowered to
+// the distance equivalent to the source quality, so the output is not
+// quantized more coarsely than the source. Other formats pass unchanged.
+func checkSourceQuality(minQuality int, matchQuality bool, sourcePath string, distance float64) (imagemeta.QualityEstimate, float64, error) {
+	estimate, err := imagemeta.ReadQuality(sourcePath)
+	if err != nil {
+		// Not a JPEG, or broken in a way cjpegli reports itself
+		return imagemeta.QualityEstimate{}, distance, nil
+	}
+	if estimate.Quality < minQuality {
+		return estimate, distance, fmt.Errorf("%w (%s): %s", ErrLowQualitySkipped, estimate, sourcePath)
+	}
+	if matchQuality {
+		distance = math.Min(distance, qualityDistance(estimate.Quality))
+	}
+	return estimate, distance, nil
+}
+
+// qualityDistance converts a libjpeg quality to the jpegli distance, as cjpegli
+// does for its --quality option, rounded to the precision passed to cjpegli.
+func qualityDistance(quality int) float64 {
+	q := float64(quality)
+	var distance float64
+	switch {
+	case quality >= 100:
+		distance = 0.01
+	case quality >= 30:
+		distance = 0.1 + (100-q)*0.09
+	default:
+		distance = 53.0/3000.0*q*q - 23.0/20.0*q + 25.0
+	}
+	// Distances below 0.1 would be rounded to lossless 0.0
+	return math.Max(float64(distanceStep(distance))/10, 0.1)
+}
@@ -0,0 +1,81 @@
+package convert
+
+import (
+	"errors"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestQualityDistance(t *testing.T) {
+	tests := []struct {
+		quality int
+		want    float64
+	}{
+		{quality: 100, want: 0.1},
+		{quality: 94, want: 0.6},
+		{quality: 90, want: 1.0},
+		{quality: 75, want: 2.4},
+		{quality: 30, want: 6.4},
+		{quality: 10, want: 15.3},
+		{quality: 1, want: 23.9},
+	}
+	for _, tt := range tests {
+		if got := qualityDistance(tt.quality); got != tt.want {
+			t.Errorf("qualityDistance(%d) = %v, want %v", tt.quality, got, tt.want)
+		}
+	}
+}
+
+func writeQualityJPEG(t *testing.T, path string, quality int) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := jpeg.Encode(f, image.NewGray(image.Rect(0, 0, 8, 8)), &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckSourceQuality(t *testing.T) {
+	dir := t.TempDir()
+	low := filepath.Join(dir, "low.jpg")
+	writeQualityJPEG(t, low, 50)
+	high := filepath.Join(dir, "high.jpg")
+	writeQualityJPEG(t, high, 94)
+	png := filepath.Join(dir, "image.png")
+	writeTestImage(t, png, 8, 8)
+
+	tests := []struct {
+		name         string
+		minQuality   int
+		match        bool
+		path         string
+		distance     float64
+		wantQuality  int
+		wantDistance float64
+		wantSkip     bool
+	}{
+		{name: "report only", path: low, distance: 1.0, wantQuality: 50, wantDistance: 1.0},
+		{name: "below minimum", minQuality: 60, path: low, distance: 1.0, wantQuality: 50, wantDistance: 1.0, wantSkip: true},
+		{name: "at minimum", minQuality: 50, path: low, distance: 1.0, wantQuality: 50, wantDistance: 1.0},
+		{name: "distance lowered", match: true, path: high, distance: 1.0, wantQuality: 94, wantDistance: 0.6},
+		{name: "distance not raised", match: true, path: low, distance: 1.0, wantQuality: 50, wantDistance: 1.0},
+		{name: "png", minQuality: 90, match: true, path: png, distance: 1.0, wantDistance: 1.0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate, distance, err := checkSourceQuality(tt.minQuality, tt.match, tt.path, tt.distance)
+			if errors.Is(err, ErrLowQualitySkipped) != tt.wantSkip {
+				t.Fatalf("checkSourceQuality() error = %v, want skip %v", err, tt.wantSkip)
+			}
+			if estimate.Quality != tt.wantQuality || distance != tt.wantDistance {
+				t.Errorf("checkSourceQuality() = %d, %v, want %d, %v", estimate.Quality, distance, tt.wantQuality, tt.wantDistance)
+			}
+		})
+	}
+}
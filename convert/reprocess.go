@@ -0,0 +1,126 @@
+package convert
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ReprocessPolicy decides whether a file carrying the processed marker is
+// encoded again.
+type ReprocessPolicy string
+
+const (
+	// ReprocessNever skips every file carrying the marker
+	ReprocessNever ReprocessPolicy = "never"
+	// ReprocessChanged reprocesses files whose marker records another distance
+	// or profile, or a tool version older than the configured minimum
+	ReprocessChanged ReprocessPolicy = "changed"
+	// ReprocessAlways reprocesses every file
+	ReprocessAlways ReprocessPolicy = "always"
+)
+
+// ReprocessCriteria are the current settings a marker is compared with.
+type ReprocessCriteria struct {
+	Policy   ReprocessPolicy
+	Distance float64
+	Profile  string
+	// MinVersion is the oldest tool version whose outputs are kept by
+	// ReprocessChanged, empty to ignore the version
+	MinVersion string
+}
+
+// ShouldReprocess reports whether a file with the given marker tags is encoded
+// again, and if not, why. Regardless of the policy a file is never re-encoded
+// at a worse distance than recorded, as every lossy pass loses quality.
+func (c ReprocessCriteria) ShouldReprocess(metadata FileMetadata) (bool, string, error) {
+	if strings.TrimSpace(metadata.OptimizedBy) == "" {
+		return true, "", nil
+	}
+	processedBy := fmt.Sprintf("processed by: %s", metadata.OptimizedBy)
+	if metadata.DistanceKnown && distanceStep(c.Distance) > distanceStep(metadata.Distance) {
+		return false, fmt.Sprintf("already processed at distance %.1f, not re-encoding at worse distance %.1f (%s)",
+			metadata.Distance, c.Distance, processedBy), nil
+	}
+
+	switch c.Policy {
+	case "", ReprocessNever:
+		return false, fmt.Sprintf("already processed (%s)", processedBy), nil
+	case ReprocessAlways:
+		return true, "", nil
+	case ReprocessChanged:
+		if metadata.DistanceKnown && distanceStep(c.Distance) != distanceStep(metadata.Distance) {
+			return true, "", nil
+		}
+		if metadata.Profile != "" && metadata.Profile != c.Profile {
+			return true, "", nil
+		}
+		if c.MinVersion != "" && versionOlder(markerVersion(metadata.OptimizedBy), c.MinVersion) {
+			return true, "", nil
+		}
+		return false, fmt.Sprintf("already processed with current settings (%s)", processedBy), nil
+	}
+	return false, "", fmt.Errorf("unknown reprocess policy: %q", c.Policy)
+}
+
+// distanceStep rounds a distance to the precision passed to cjpegli.
+func distanceStep(distance float64) int64 {
+	return int64(math.Round(distance * 10))
+}
+
+// markerVersion returns the version part of an OptimizedBy marker,
+// "jpegli-windows-explorer-extension 1.2.3" yields "1.2.3".
+func markerVersion(optimizedBy string) string {
+	fields := strings.Fields(optimizedBy)
+	if len(fields) < 2 {
+		return ""
+	}
+	return fields[len(fields)-1]
+}
+
+// versionOlder reports whether version is older than minimum. Versions are
+// compared numerically per dot separated part, a leading "v" is ignored. A
+// version that cannot be parsed counts as older.
+func versionOlder(version, minimum string) bool {
+	v, ok := parseVersion(version)
+	if !ok {
+		return true
+	}
+	m, ok := parseVersion(minimum)
+	if !ok {
+		return false
+	}
+	for i := 0; i < len(v) || i < len(m); i++ {
+		var a, b int
+		if i < len(v) {
+			a = v[i]
+		}
+		if i < len(m) {
+			b = m[i]
+		}
+		if a != b {
+			return a < b
+		}
+	}
+	return false
+}
+
+func parseVersion(version string) ([]int, bool) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	// Ignore pre-release and build suffixes, e.g. 1.2.3-rc1
+	version, _, _ = strings.Cut(version, "-")
+	version, _, _ = strings.Cut(version, "+")
+	if version == "" {
+		return nil, false
+	}
+	var parts []int
+	for _, part := range strings.Split(version, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+		parts = append(parts, n)
+	}
+	return parts, true
+}
@@ -0,0 +1,55 @@
+package convert
+
+import "testing"
+
+func TestShouldReprocess(t *testing.T) {
+	marked := func(version string, distance float64, profile string) FileMetadata {
+		return FileMetadata{
+			OptimizedBy:   "jpegli-windows-explorer-extension " + version,
+			Distance:      distance,
+			DistanceKnown: true,
+			Profile:       profile,
+		}
+	}
+	legacy := FileMetadata{OptimizedBy: "jpegli-windows-explorer-extension 1.0.0"}
+
+	tests := []struct {
+		name     string
+		criteria ReprocessCriteria
+		metadata FileMetadata
+		want     bool
+		wantErr  bool
+	}{
+		{"unmarked file", ReprocessCriteria{Policy: ReprocessNever}, FileMetadata{}, true, false},
+		{"never skips marked file", ReprocessCriteria{Policy: ReprocessNever, Distance: 0.5}, marked("1.0.0", 0.5, "default"), false, false},
+		{"empty policy is never", ReprocessCriteria{Distance: 0.5}, legacy, false, false},
+		{"always reprocesses", ReprocessCriteria{Policy: ReprocessAlways, Distance: 0.5}, marked("1.0.0", 0.5, "default"), true, false},
+		{"always reprocesses legacy marker", ReprocessCriteria{Policy: ReprocessAlways, Distance: 3}, legacy, true, false},
+		{"always never at worse distance", ReprocessCriteria{Policy: ReprocessAlways, Distance: 1.0}, marked("1.0.0", 0.5, "default"), false, false},
+		{"changed with same settings", ReprocessCriteria{Policy: ReprocessChanged, Distance: 0.5, Profile: "default"}, marked("1.0.0", 0.5, "default"), false, false},
+		{"changed with better distance", ReprocessCriteria{Policy: ReprocessChanged, Distance: 0.3, Profile: "default"}, marked("1.0.0", 0.5, "default"), true, false},
+		{"changed with worse distance", ReprocessCriteria{Policy: ReprocessChanged, Distance: 2.0, Profile: "default"}, marked("1.0.0", 0.5, "default"), false, false},
+		{"changed with rounding only", ReprocessCriteria{Policy: ReprocessChanged, Distance: 0.51, Profile: "default"}, marked("1.0.0", 0.5, "default"), false, false},
+		{"changed with other profile", ReprocessCriteria{Policy: ReprocessChanged, Distance: 0.5, Profile: "web"}, marked("1.0.0", 0.5, "default"), true, false},
+		{"changed with older version", ReprocessCriteria{Policy: ReprocessChanged, Distance: 0.5, Profile: "default", MinVersion: "1.2"}, marked("1.1.9", 0.5, "default"), true, false},
+		{"changed with newer version", ReprocessCriteria{Policy: ReprocessChanged, Distance: 0.5, Profile: "default", MinVersion: "v1.2.0"}, marked("v1.10.0", 0.5, "default"), false, false},
+		{"changed with unparsable version", ReprocessCriteria{Policy: ReprocessChanged, Distance: 0.5, Profile: "default", MinVersion: "1.2.0"}, marked("UNSET", 0.5, "default"), true, false},
+		{"changed legacy marker without minimum", ReprocessCriteria{Policy: ReprocessChanged, Distance: 0.5}, legacy, false, false},
+		{"unknown policy", ReprocessCriteria{Policy: "sometimes"}, legacy, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason, err := tt.criteria.ShouldReprocess(tt.metadata)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ShouldReprocess() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ShouldReprocess() = %v, want %v", got, tt.want)
+			}
+			if !got && !tt.wantErr && reason == "" {
+				t.Errorf("ShouldReprocess() returned no skip reason")
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package convert
+
+import (
+	"fmt"
+
+	"github.com/dhcgn/jpegli-windows-explorer-extension/filehandling"
+)
+
+// SidecarPolicy decides what happens with XMP sidecar files of a source.
+type SidecarPolicy string
+
+const (
+	// SidecarIgnore leaves sidecars untouched
+	SidecarIgnore SidecarPolicy = "ignore"
+	// SidecarCopy copies sidecars next to the output, renamed to match it
+	SidecarCopy SidecarPolicy = "copy"
+	// SidecarMerge merges the sidecar XMP into the XMP embedded in the output
+	SidecarMerge SidecarPolicy = "merge"
+)
+
+func (p SidecarPolicy) normalize() (SidecarPolicy, error) {
+	switch p {
+	case "":
+		return SidecarIgnore, nil
+	case SidecarIgnore, SidecarCopy, SidecarMerge:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown sidecar policy: %q", p)
+}
+
+// sidecarArgs returns the exiftool arguments merging the XMP of all sidecars
+// into the output. Placed after the source copy, sidecar values win over the
+// XMP of the source image. The metadata filter applies to sidecars as well, so
+// they cannot bring back removed tags.
+func sidecarArgs(metadata metadataFilter, sidecars []string) []string {
+	if len(metadata.Allow) == 0 {
+		metadata.Allow = []string{"xmp:all"}
+	}
+	var args []string
+	for _, sidecar := range sidecars {
+		args = append(args, metadata.copyArgs(sidecar, false)...)
+	}
+	return args
+}
+
+// copySidecars copies all sidecars next to outputPath, renamed to match it.
+// Existing files are never replaced, failures are returned as warnings.
+func copySidecars(sidecars []string, sourcePath, outputPath string) []string {
+	var warnings []string
+	for _, sidecar := range sidecars {
+		target := filehandling.SidecarPath(sidecar, sourcePath, outputPath)
+		if target == sidecar {
+			// In place replacement, the sidecar already belongs to the output
+			continue
+		}
+		if err := filehandling.MirrorFile(sidecar, target, filehandling.MirrorCopy); err != nil {
+			warnings = append(warnings, fmt.Sprintf("could not copy sidecar %s: %s", sidecar, err))
+		}
+	}
+	return warnings
+}
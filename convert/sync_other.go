@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package convert
+
+import "os"
+
+// syncDir flushes the directory entry of dir, so a preceding rename survives a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
@@ -0,0 +1,10 @@
+//go:build windows
+// +build windows
+
+package convert
+
+// syncDir is a no-op on Windows: directories cannot be opened for syncing and
+// NTFS journals the rename itself.
+func syncDir(dir string) error {
+	return nil
+}
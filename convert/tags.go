@@ -0,0 +1,82 @@
+package convert
+
+import (
+	"sort"
+	"strings"
+)
+
+// Tags set by the Artist, Copyright and Credit options, so one value lands in
+// EXIF, XMP and IPTC alike.
+var (
+	artistTags    = []string{"Artist", "XMP-dc:Creator", "IPTC:By-line"}
+	copyrightTags = []string{"Copyright", "XMP-dc:Rights", "IPTC:CopyrightNotice"}
+	creditTags    = []string{"IPTC:Credit", "XMP-photoshop:Credit"}
+)
+
+// tagAssignment is a tag written to every output. A value containing "$" is an
+// exiftool template filled from the tags of the source, e.g. "(c) $Make owner".
+type tagAssignment struct {
+	Tag   string
+	Value string
+}
+
+func (a tagAssignment) templated() bool {
+	return strings.Contains(a.Value, "$")
+}
+
+// injectedTags expands the tag options of opts. A custom tag overrides the same
+// tag set by Artist, Copyright or Credit.
+func injectedTags(opts Options) []tagAssignment {
+	var tags []tagAssignment
+	set := func(name, value string) {
+		for i := range tags {
+			if strings.EqualFold(tags[i].Tag, name) {
+				tags[i].Value = value
+				return
+			}
+		}
+		tags = append(tags, tagAssignment{Tag: name, Value: value})
+	}
+	preset := func(names []string, value string) {
+		if value == "" {
+			return
+		}
+		for _, name := range names {
+			set(name, value)
+		}
+	}
+	preset(artistTags, opts.Artist)
+	preset(copyrightTags, opts.Copyright)
+	preset(creditTags, opts.Credit)
+
+	names := make([]string, 0, len(opts.SetTags))
+	for name := range opts.SetTags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		set(name, opts.SetTags[name])
+	}
+	return tags
+}
+
+// tagArgs returns the exiftool arguments writing tags. Templates are copied
+// from sourcePath with their own -TagsFromFile, plain values are assigned.
+func tagArgs(sourcePath string, tags []tagAssignment) []string {
+	var args []string
+	for _, tag := range tags {
+		if !tag.templated() {
+			continue
+		}
+		if len(args) == 0 {
+			args = append(args, "-TagsFromFile", sourcePath)
+		}
+		args = append(args, "-"+tag.Tag+"<"+tag.Value)
+	}
+	for _, tag := range tags {
+		if !tag.templated() {
+			args = append(args, "-"+tag.Tag+"="+tag.Value)
+		}
+	}
+	return args
+}
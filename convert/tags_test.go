@@ -0,0 +1,53 @@
+package convert
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTagArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{
+			name: "no tags",
+			opts: Options{},
+			want: nil,
+		},
+		{
+			name: "copyright preset",
+			opts: Options{Copyright: "(c) 2024 Jane Doe"},
+			want: []string{"-Copyright=(c) 2024 Jane Doe", "-XMP-dc:Rights=(c) 2024 Jane Doe",
+				"-IPTC:CopyrightNotice=(c) 2024 Jane Doe"},
+		},
+		{
+			name: "artist and credit presets",
+			opts: Options{Artist: "Jane Doe", Credit: "Studio"},
+			want: []string{"-Artist=Jane Doe", "-XMP-dc:Creator=Jane Doe", "-IPTC:By-line=Jane Doe",
+				"-IPTC:Credit=Studio", "-XMP-photoshop:Credit=Studio"},
+		},
+		{
+			name: "custom tags sorted, templates copied from source",
+			opts: Options{SetTags: map[string]string{
+				"XMP-dc:Source": "$Make $Model",
+				"IPTC:City":     "Berlin",
+			}},
+			want: []string{"-TagsFromFile", "source.jpg", "-XMP-dc:Source<$Make $Model", "-IPTC:City=Berlin"},
+		},
+		{
+			name: "custom tag overrides preset",
+			opts: Options{Artist: "Jane Doe", SetTags: map[string]string{"artist": "John Doe"}},
+			want: []string{"-Artist=John Doe", "-XMP-dc:Creator=Jane Doe", "-IPTC:By-line=Jane Doe"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tagArgs("source.jpg", injectedTags(tt.opts)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tagArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
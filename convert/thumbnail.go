@@ -0,0 +1,100 @@
+package convert
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+)
+
+// ThumbnailPolicy decides what happens to the EXIF thumbnail copied from the
+// source, which shows the original pixels.
+type ThumbnailPolicy string
+
+const (
+	// ThumbnailKeep copies the thumbnail of the source unchanged
+	ThumbnailKeep ThumbnailPolicy = "keep"
+	// ThumbnailRegenerate replaces the thumbnail with one made from the output
+	ThumbnailRegenerate ThumbnailPolicy = "regenerate"
+	// ThumbnailRemove drops the thumbnail
+	ThumbnailRemove ThumbnailPolicy = "remove"
+)
+
+func (p ThumbnailPolicy) normalize() (ThumbnailPolicy, error) {
+	switch p {
+	case "":
+		return ThumbnailKeep, nil
+	case ThumbnailKeep, ThumbnailRegenerate, ThumbnailRemove:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown thumbnail policy: %q", p)
+}
+
+// thumbnailTag is the category of the EXIF thumbnail for metadataFilter.removes.
+var thumbnailTag = importantTag{Name: "thumbnail", Keywords: []string{"thumbnail"}}
+
+// Thumbnail size recommended by EXIF, and quality of the regenerated thumbnail.
+const (
+	thumbnailWidth   = 160
+	thumbnailHeight  = 120
+	thumbnailQuality = 85
+	// thumbnailSamples is the number of source pixels averaged per axis and
+	// thumbnail pixel, enough to avoid aliasing without reading every pixel
+	thumbnailSamples = 4
+)
+
+// writeThumbnail writes a thumbnail of the JPEG at imagePath into dir, to be
+// set with exiftool. The caller removes the returned file.
+func writeThumbnail(imagePath, dir string) (string, error) {
+	src, err := os.Open(imagePath)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	img, err := jpeg.Decode(src)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := os.CreateTemp(dir, ".jpegli-*.jpg")
+	if err != nil {
+		return "", err
+	}
+	if err := jpeg.Encode(out, thumbnail(img), &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// thumbnail scales img down to fit thumbnailWidth x thumbnailHeight, keeping
+// the aspect ratio. Each thumbnail pixel averages a grid of source pixels.
+func thumbnail(img image.Image) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	scale := min(float64(thumbnailWidth)/float64(w), float64(thumbnailHeight)/float64(h), 1)
+	tw, th := max(1, int(float64(w)*scale+0.5)), max(1, int(float64(h)*scale+0.5))
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		for x := 0; x < tw; x++ {
+			var r, g, b, n uint32
+			for sy := 0; sy < thumbnailSamples; sy++ {
+				for sx := 0; sx < thumbnailSamples; sx++ {
+					px := bounds.Min.X + (x*thumbnailSamples+sx)*w/(tw*thumbnailSamples)
+					py := bounds.Min.Y + (y*thumbnailSamples+sy)*h/(th*thumbnailSamples)
+					pr, pg, pb, _ := img.At(px, py).RGBA()
+					r, g, b, n = r+pr, g+pg, b+pb, n+1
+				}
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: 0xffff})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,44 @@
+package convert
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteThumbnail(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "photo.jpg")
+	img := image.NewRGBA(image.Rect(0, 0, 1000, 500))
+	for y := 0; y < 500; y++ {
+		for x := 0; x < 1000; x++ {
+			img.Set(x, y, color.RGBA{R: 200, G: 100, B: 50, A: 255})
+		}
+	}
+	writeImage(t, source, img)
+
+	path, err := writeThumbnail(source, dir)
+	if err != nil {
+		t.Fatalf("writeThumbnail() error = %v", err)
+	}
+	defer os.Remove(path)
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	thumb, err := jpeg.Decode(f)
+	if err != nil {
+		t.Fatalf("thumbnail is not a JPEG: %v", err)
+	}
+	if bounds := thumb.Bounds(); bounds.Dx() != 160 || bounds.Dy() != 80 {
+		t.Errorf("thumbnail size = %dx%d, want 160x80", bounds.Dx(), bounds.Dy())
+	}
+	r, g, b, _ := thumb.At(80, 40).RGBA()
+	if r>>8 < 190 || g>>8 < 90 || g>>8 > 110 || b>>8 > 60 {
+		t.Errorf("thumbnail color = %d,%d,%d, want about 200,100,50", r>>8, g>>8, b>>8)
+	}
+}
@@ -0,0 +1,38 @@
+package convert
+
+import (
+	"fmt"
+
+	"github.com/dhcgn/jpegli-windows-explorer-extension/imagemeta"
+)
+
+// transplantMarkers resolves the configured segment names, e.g. "APP13".
+func transplantMarkers(names []string) ([]byte, error) {
+	var markers []byte
+	for _, name := range names {
+		marker, err := imagemeta.ParseSegmentName(name)
+		if err != nil {
+			return nil, fmt.Errorf("transplant segments: %w", err)
+		}
+		markers = append(markers, marker)
+	}
+	return markers, nil
+}
+
+// transplantSegments copies the segments of markers verbatim from the source
+// into the output. It returns the names of the copied segments; a failure is
+// returned as warning, the output is valid without them.
+func transplantSegments(sourcePath, targetPath string, markers []byte) ([]string, []string) {
+	if len(markers) == 0 {
+		return nil, nil
+	}
+	copied, err := imagemeta.WriteTransplant(targetPath, sourcePath, markers)
+	if err != nil {
+		return nil, []string{fmt.Sprintf("segments of %s not transplanted: %s", sourcePath, err)}
+	}
+	var names []string
+	for _, marker := range copied {
+		names = append(names, imagemeta.SegmentName(marker))
+	}
+	return names, nil
+}
@@ -0,0 +1,85 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"os"
+	"strings"
+
+	"github.com/dhcgn/jpegli-windows-explorer-extension/imagemeta"
+)
+
+// UnusualJPEGPolicy decides how JPEGs that may not round-trip through cjpegli
+// are handled: CMYK and YCCK, 12-bit, arithmetic, lossless or hierarchical
+// coded files, see imagemeta.JPEGFrame.Unusual.
+type UnusualJPEGPolicy string
+
+const (
+	// UnusualJPEGSkip leaves such files untouched and reports why
+	UnusualJPEGSkip UnusualJPEGPolicy = "skip"
+	// UnusualJPEGConvert passes them to cjpegli like any other file, the
+	// output verification catches results that don't match the source
+	UnusualJPEGConvert UnusualJPEGPolicy = "convert"
+	// UnusualJPEGToRGB converts CMYK and YCCK files to RGB before encoding,
+	// other unusual files are skipped
+	UnusualJPEGToRGB UnusualJPEGPolicy = "to_rgb"
+)
+
+// ErrUnusualJPEGSkipped is returned for files skipped due to UnusualJPEGSkip or UnusualJPEGToRGB.
+var ErrUnusualJPEGSkipped = errors.New("skipped unusual JPEG")
+
+func (p UnusualJPEGPolicy) normalize() (UnusualJPEGPolicy, error) {
+	switch p {
+	case "":
+		return UnusualJPEGSkip, nil
+	case UnusualJPEGSkip, UnusualJPEGConvert, UnusualJPEGToRGB:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown unusual JPEG policy: %q", p)
+}
+
+// checkUnusualJPEG inspects the frame header of a JPEG source before anything
+// is written. It reports whether the pixels have to be converted to RGB, and
+// returns ErrUnusualJPEGSkipped for files the policy skips. Other formats pass.
+func checkUnusualJPEG(policy UnusualJPEGPolicy, sourcePath string) (bool, []string, error) {
+	frame, err := imagemeta.ReadJPEGFrame(sourcePath)
+	if err != nil {
+		// Not a JPEG, or broken in a way cjpegli reports itself
+		return false, nil, nil
+	}
+	reasons := frame.Unusual()
+	if len(reasons) == 0 {
+		return false, nil, nil
+	}
+	switch policy {
+	case UnusualJPEGConvert:
+		return false, []string{fmt.Sprintf("unusual JPEG (%s) encoded as is", frame)}, nil
+	case UnusualJPEGToRGB:
+		// Go decodes 8-bit sequential and progressive CMYK, nothing else
+		if frame.Components == 4 && len(reasons) == 1 {
+			return true, []string{fmt.Sprintf("%s converted to RGB without color management, colors are approximate", frame.ColorModel())}, nil
+		}
+	}
+	return false, nil, fmt.Errorf("%w (%s): %s", ErrUnusualJPEGSkipped, strings.Join(reasons, ", "), sourcePath)
+}
+
+// rgbCopy decodes the CMYK JPEG at sourcePath and writes it as RGB PNG into
+// dir, as cjpegli input. The caller removes the returned file.
+func rgbCopy(sourcePath, dir string) (string, error) {
+	src, err := os.Open(sourcePath)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	img, err := jpeg.Decode(src)
+	if err != nil {
+		return "", fmt.Errorf("source not decodable: %w", err)
+	}
+	bounds := img.Bounds()
+	rgb := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgb, rgb.Bounds(), img, bounds.Min, draw.Src)
+	return writeTempPNG(rgb, dir)
+}
@@ -0,0 +1,62 @@
+package convert
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// cmykHeader is the start of a baseline CMYK JPEG with an Adobe segment, up
+// to its first scan.
+const cmykHeader = "\xff\xd8" +
+	"\xff\xee\x00\x0eAdobe\x00\x64\x00\x00\x00\x00\x00" +
+	"\xff\xc0\x00\x14\x08\x00\x10\x00\x10\x04\x01\x11\x00\x02\x11\x00\x03\x11\x00\x04\x11\x00" +
+	"\xff\xda\x00\x02"
+
+// arithmeticHeader is the start of an arithmetic coded YCbCr JPEG.
+const arithmeticHeader = "\xff\xd8" +
+	"\xff\xc9\x00\x11\x08\x00\x10\x00\x10\x03\x01\x11\x00\x02\x11\x00\x03\x11\x00" +
+	"\xff\xda\x00\x02"
+
+func TestCheckUnusualJPEG(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.jpg")
+	writeTestImage(t, plain, 8, 8)
+	png := filepath.Join(dir, "image.png")
+	writeTestImage(t, png, 8, 8)
+	cmyk := filepath.Join(dir, "cmyk.jpg")
+	arithmetic := filepath.Join(dir, "arithmetic.jpg")
+	for path, content := range map[string]string{cmyk: cmykHeader, arithmetic: arithmeticHeader} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		policy       UnusualJPEGPolicy
+		path         string
+		wantRGB      bool
+		wantWarnings int
+		wantSkip     bool
+	}{
+		{name: "plain", policy: UnusualJPEGSkip, path: plain},
+		{name: "png", policy: UnusualJPEGSkip, path: png},
+		{name: "cmyk skipped", policy: UnusualJPEGSkip, path: cmyk, wantSkip: true},
+		{name: "cmyk converted as is", policy: UnusualJPEGConvert, path: cmyk, wantWarnings: 1},
+		{name: "cmyk to rgb", policy: UnusualJPEGToRGB, path: cmyk, wantRGB: true, wantWarnings: 1},
+		{name: "arithmetic with to_rgb", policy: UnusualJPEGToRGB, path: arithmetic, wantSkip: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rgb, warnings, err := checkUnusualJPEG(tt.policy, tt.path)
+			if errors.Is(err, ErrUnusualJPEGSkipped) != tt.wantSkip {
+				t.Fatalf("checkUnusualJPEG() error = %v, want skip %v", err, tt.wantSkip)
+			}
+			if rgb != tt.wantRGB || len(warnings) != tt.wantWarnings {
+				t.Errorf("checkUnusualJPEG() = %v, %q, want %v and %d warning(s)", rgb, warnings, tt.wantRGB, tt.wantWarnings)
+			}
+		})
+	}
+}
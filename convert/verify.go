@@ -0,0 +1,93 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"os"
+)
+
+// ErrOutputVerification is returned when the encoded output is not a complete
+// image matching the source.
+var ErrOutputVerification = errors.New("output verification failed")
+
+// imageShape describes the properties of an image that must survive re-encoding.
+type imageShape struct {
+	Width      int
+	Height     int
+	Components int // 0 if unknown
+}
+
+// verifyOutput fully decodes outputPath as JPEG, which also catches truncated
+// files, and compares width, height and component count with sourcePath.
+// The comparison is skipped for source formats Go cannot decode (e.g. JXL or PNM).
+func verifyOutput(sourcePath, outputPath string) error {
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		return fmt.Errorf("error getting output file info: %w", err)
+	}
+	if info.Size() == 0 {
+		return fmt.Errorf("%w: output file is empty", ErrOutputVerification)
+	}
+
+	out, err := os.Open(outputPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	outImg, err := jpeg.Decode(out)
+	if err != nil {
+		return fmt.Errorf("%w: output is not a valid JPEG: %v", ErrOutputVerification, err)
+	}
+	outShape := shapeOf(outImg)
+
+	src, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	srcImg, _, err := image.Decode(src)
+	if err != nil {
+		// Unsupported source format, nothing to compare against
+		return nil
+	}
+	srcShape := shapeOf(srcImg)
+
+	if srcShape.Width != outShape.Width || srcShape.Height != outShape.Height {
+		return fmt.Errorf("%w: dimensions mismatch: source %dx%d, output %dx%d", ErrOutputVerification,
+			srcShape.Width, srcShape.Height, outShape.Width, outShape.Height)
+	}
+	if srcShape.Components != 0 && srcShape.Components != outShape.Components {
+		return fmt.Errorf("%w: component count mismatch: source %d, output %d", ErrOutputVerification,
+			srcShape.Components, outShape.Components)
+	}
+	return nil
+}
+
+func shapeOf(img image.Image) imageShape {
+	bounds := img.Bounds()
+	return imageShape{
+		Width:      bounds.Dx(),
+		Height:     bounds.Dy(),
+		Components: componentCount(img.ColorModel()),
+	}
+}
+
+// componentCount returns the number of JPEG color components an image with the
+// given color model is encoded with. Alpha is ignored because JPEG cannot store it.
+// Palette based images return 0 as the encoder may pick either gray or color.
+func componentCount(model color.Model) int {
+	switch model {
+	case color.GrayModel, color.Gray16Model:
+		return 1
+	case color.CMYKModel:
+		return 4
+	case color.YCbCrModel, color.RGBAModel, color.RGBA64Model, color.NRGBAModel, color.NRGBA64Model:
+		return 3
+	}
+	return 0
+}
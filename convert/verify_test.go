@@ -0,0 +1,97 @@
+package convert
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestImage(t *testing.T, path string, width, height int) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	writeImage(t, path, img)
+}
+
+func writeImage(t *testing.T, path string, img image.Image) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create %s: %v", path, err)
+	}
+	defer f.Close()
+	if filepath.Ext(path) == ".png" {
+		err = png.Encode(f, img)
+	} else {
+		err = jpeg.Encode(f, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("Failed to encode %s: %v", path, err)
+	}
+}
+
+func TestVerifyOutput(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.png")
+	writeTestImage(t, source, 32, 16)
+
+	sameSize := filepath.Join(dir, "same.jpg")
+	writeTestImage(t, sameSize, 32, 16)
+	otherSize := filepath.Join(dir, "other.jpg")
+	writeTestImage(t, otherSize, 16, 16)
+	empty := filepath.Join(dir, "empty.jpg")
+	if err := os.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatalf("Failed to write empty file: %v", err)
+	}
+	grayOutput := filepath.Join(dir, "gray.jpg")
+	writeImage(t, grayOutput, image.NewGray(image.Rect(0, 0, 32, 16)))
+	truncated := filepath.Join(dir, "truncated.jpg")
+	data, err := os.ReadFile(sameSize)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", sameSize, err)
+	}
+	if err := os.WriteFile(truncated, data[:len(data)/2], 0644); err != nil {
+		t.Fatalf("Failed to write truncated file: %v", err)
+	}
+	notJpeg := filepath.Join(dir, "not-jpeg.jpg")
+	writeTestImage(t, filepath.Join(dir, "not-jpeg.png"), 32, 16)
+	if err := os.Rename(filepath.Join(dir, "not-jpeg.png"), notJpeg); err != nil {
+		t.Fatalf("Failed to rename: %v", err)
+	}
+
+	tests := []struct {
+		name             string
+		output           string
+		wantErr          bool
+		wantVerification bool
+	}{
+		{name: "valid output with matching dimensions", output: sameSize, wantErr: false},
+		{name: "dimensions mismatch", output: otherSize, wantErr: true, wantVerification: true},
+		{name: "component count mismatch", output: grayOutput, wantErr: true, wantVerification: true},
+		{name: "truncated output", output: truncated, wantErr: true, wantVerification: true},
+		{name: "empty output", output: empty, wantErr: true, wantVerification: true},
+		{name: "output is not a JPEG", output: notJpeg, wantErr: true, wantVerification: true},
+		{name: "missing output", output: filepath.Join(dir, "missing.jpg"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyOutput(source, tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyOutput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantVerification && !errors.Is(err, ErrOutputVerification) {
+				t.Fatalf("verifyOutput() error = %v, want ErrOutputVerification", err)
+			}
+		})
+	}
+}
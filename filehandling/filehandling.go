@@ -3,6 +3,7 @@ package filehandling
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 )
 
 func IsPathDir(path string) (bool, error) {
@@ -42,7 +43,7 @@ func GetAllFilesInDirectory(filter func(string) bool, path string, warn func(str
 	}
 	// Process each entry in the directory
 	for _, entry := range entries {
-		fullPath := path + string(os.PathSeparator) + entry.Name()
+		fullPath := filepath.Join(path, entry.Name())
 
 		if entry.IsDir() {
 			warn(fmt.Sprintf("Skipping is directory: %s", fullPath))
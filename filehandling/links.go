@@ -0,0 +1,45 @@
+package filehandling
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// LinkInfo describes how a file is linked on the filesystem.
+type LinkInfo struct {
+	// IsSymlink is true if the path itself is a symbolic link
+	IsSymlink bool
+	// LinkCount is the number of hard links of the file the path resolves to
+	LinkCount uint64
+	// ResolvedPath is the path with all symbolic links resolved
+	ResolvedPath string
+}
+
+// IsLinked reports whether replacing the file by rename would break a link.
+func (l LinkInfo) IsLinked() bool {
+	return l.IsSymlink || l.LinkCount > 1
+}
+
+// GetLinkInfo detects whether path is a symbolic link or a file with multiple hard links.
+func GetLinkInfo(path string) (LinkInfo, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return LinkInfo{}, err
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return LinkInfo{}, err
+	}
+
+	count, err := linkCount(resolved)
+	if err != nil {
+		return LinkInfo{}, err
+	}
+
+	return LinkInfo{
+		IsSymlink:    info.Mode()&os.ModeSymlink != 0,
+		LinkCount:    count,
+		ResolvedPath: resolved,
+	}, nil
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package filehandling
+
+import (
+	"os"
+	"syscall"
+)
+
+// linkCount returns the number of hard links of the file at path.
+func linkCount(path string) (uint64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Nlink), nil
+	}
+	return 1, nil
+}
@@ -0,0 +1,68 @@
+package filehandling
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestGetLinkInfo(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks requires elevated rights on Windows")
+	}
+	dir := t.TempDir()
+	regular := filepath.Join(dir, "regular.jpg")
+	if err := os.WriteFile(regular, []byte("regular"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	linked := filepath.Join(dir, "linked.jpg")
+	if err := os.WriteFile(linked, []byte("linked"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	hardLink := filepath.Join(dir, "hardlink.jpg")
+	if err := os.Link(linked, hardLink); err != nil {
+		t.Fatalf("Failed to create hard link: %v", err)
+	}
+	symlink := filepath.Join(dir, "symlink.jpg")
+	if err := os.Symlink(regular, symlink); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	resolvedRegular, err := filepath.EvalSymlinks(regular)
+	if err != nil {
+		t.Fatalf("Failed to resolve %s: %v", regular, err)
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		wantSymlink  bool
+		wantCount    uint64
+		wantResolved string
+	}{
+		{name: "regular file", path: regular, wantSymlink: false, wantCount: 1, wantResolved: resolvedRegular},
+		{name: "hard linked file", path: hardLink, wantSymlink: false, wantCount: 2},
+		{name: "symlink", path: symlink, wantSymlink: true, wantCount: 1, wantResolved: resolvedRegular},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := GetLinkInfo(tt.path)
+			if err != nil {
+				t.Fatalf("GetLinkInfo() error = %v", err)
+			}
+			if info.IsSymlink != tt.wantSymlink {
+				t.Errorf("IsSymlink = %v, want %v", info.IsSymlink, tt.wantSymlink)
+			}
+			if info.LinkCount != tt.wantCount {
+				t.Errorf("LinkCount = %d, want %d", info.LinkCount, tt.wantCount)
+			}
+			if tt.wantResolved != "" && info.ResolvedPath != tt.wantResolved {
+				t.Errorf("ResolvedPath = %s, want %s", info.ResolvedPath, tt.wantResolved)
+			}
+			if info.IsLinked() != (tt.wantSymlink || tt.wantCount > 1) {
+				t.Errorf("IsLinked() = %v", info.IsLinked())
+			}
+		})
+	}
+}
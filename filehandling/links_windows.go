@@ -0,0 +1,26 @@
+//go:build windows
+// +build windows
+
+package filehandling
+
+import "syscall"
+
+// linkCount returns the number of hard links of the file at path.
+func linkCount(path string) (uint64, error) {
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	handle, err := syscall.CreateFile(pathPtr, 0, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer syscall.CloseHandle(handle)
+
+	var data syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(handle, &data); err != nil {
+		return 0, err
+	}
+	return uint64(data.NumberOfLinks), nil
+}
@@ -0,0 +1,85 @@
+package filehandling
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// MirrorMode decides how files that are not converted are carried over into the output folder.
+type MirrorMode string
+
+const (
+	// MirrorNone only writes converted images to the output folder
+	MirrorNone MirrorMode = "none"
+	// MirrorCopy copies all other files into the output folder
+	MirrorCopy MirrorMode = "copy"
+	// MirrorHardLink hard-links all other files into the output folder and
+	// falls back to copying where hard links are not possible
+	MirrorHardLink MirrorMode = "hardlink"
+)
+
+// ParseMirrorMode validates a mirror mode from the settings, empty means MirrorNone.
+func ParseMirrorMode(value string) (MirrorMode, error) {
+	switch mode := MirrorMode(value); mode {
+	case "":
+		return MirrorNone, nil
+	case MirrorNone, MirrorCopy, MirrorHardLink:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown mirror mode: %q", value)
+}
+
+// MirrorFile carries sourcePath over to targetPath according to mode.
+// An existing targetPath is never replaced.
+func MirrorFile(sourcePath, targetPath string, mode MirrorMode) error {
+	mode, err := ParseMirrorMode(string(mode))
+	if err != nil {
+		return err
+	}
+	if mode == MirrorNone {
+		return nil
+	}
+
+	if FileExists(targetPath) {
+		return fmt.Errorf("target already exists: %s", targetPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(targetPath), os.ModePerm); err != nil {
+		return err
+	}
+	if mode == MirrorHardLink {
+		if err := os.Link(sourcePath, targetPath); err == nil {
+			return nil
+		}
+	}
+	return copyFile(sourcePath, targetPath)
+}
+
+// copyFile copies content, permissions and modification time of sourcePath to a new file targetPath.
+func copyFile(sourcePath, targetPath string) error {
+	info, err := os.Stat(sourcePath)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(targetPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(targetPath)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(targetPath)
+		return err
+	}
+	return os.Chtimes(targetPath, info.ModTime(), info.ModTime())
+}
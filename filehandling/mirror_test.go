@@ -0,0 +1,49 @@
+package filehandling
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMirrorFile(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(source, []byte("sidecar"), 0644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		mode    MirrorMode
+		target  string
+		want    bool
+		wantErr bool
+	}{
+		{name: "none", mode: MirrorNone, target: filepath.Join(dir, "none", "notes.txt"), want: false},
+		{name: "copy", mode: MirrorCopy, target: filepath.Join(dir, "copy", "notes.txt"), want: true},
+		{name: "hardlink", mode: MirrorHardLink, target: filepath.Join(dir, "link", "notes.txt"), want: true},
+		{name: "existing target", mode: MirrorCopy, target: source, want: true, wantErr: true},
+		{name: "unknown mode", mode: "move", target: filepath.Join(dir, "move", "notes.txt"), want: false, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := MirrorFile(source, tt.target, tt.mode)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MirrorFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			content, err := os.ReadFile(tt.target)
+			if tt.want {
+				if err != nil {
+					t.Fatalf("Expected %s to exist: %v", tt.target, err)
+				}
+				if string(content) != "sidecar" {
+					t.Errorf("Expected content %q, got %q", "sidecar", content)
+				}
+			} else if !os.IsNotExist(err) {
+				t.Errorf("Expected %s not to exist", tt.target)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package filehandling
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NameTokens holds the values for the placeholders of an output naming template.
+type NameTokens struct {
+	// Name is the source file name without extension
+	Name string
+	// Ext is the source file extension without the leading dot
+	Ext string
+	// Dir is the name of the directory the source is in, or of the directory
+	// given as argument for folder templates
+	Dir      string
+	Profile  string
+	Distance float64
+	Date     time.Time
+	// CaptureDate, Camera and Lens come from EXIF and may be empty
+	CaptureDate time.Time
+	Camera      string
+	Lens        string
+}
+
+// ErrTokenUnavailable is returned when a template uses an EXIF based token the source has no value for.
+var ErrTokenUnavailable = errors.New("template token unavailable")
+
+var (
+	templateToken    = regexp.MustCompile(`\{([^{}]+)\}`)
+	invalidPathChars = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1f]`)
+)
+
+// NewNameTokens fills the file related tokens from sourcePath.
+func NewNameTokens(sourcePath, profile string, distance float64, date time.Time) NameTokens {
+	base := filepath.Base(sourcePath)
+	ext := filepath.Ext(base)
+	return NameTokens{
+		Name:     strings.TrimSuffix(base, ext),
+		Ext:      strings.TrimPrefix(ext, "."),
+		Dir:      filepath.Base(filepath.Dir(filepath.Clean(sourcePath))),
+		Profile:  profile,
+		Distance: distance,
+		Date:     date,
+	}
+}
+
+// RenderTemplate replaces the tokens {name}, {ext}, {jpgext}, {dir}, {profile},
+// {distance} and {date} in template. {jpgext} is the source extension for JPEG
+// sources and "jpg" otherwise. The EXIF based tokens {yyyy}, {mm}, {dd},
+// {yyyy-mm-dd}, {camera} and {lens} fail with ErrTokenUnavailable if the source
+// lacks the value. Forward slashes in the template create sub folders.
+func RenderTemplate(template string, tokens NameTokens) (string, error) {
+	var unknown, unavailable []string
+	capture := func(match, value string) string {
+		if value == "" {
+			unavailable = append(unavailable, match)
+		}
+		return value
+	}
+	captureDate := func(match, layout string) string {
+		if tokens.CaptureDate.IsZero() {
+			unavailable = append(unavailable, match)
+			return ""
+		}
+		return tokens.CaptureDate.Format(layout)
+	}
+	rendered := templateToken.ReplaceAllStringFunc(template, func(match string) string {
+		switch match {
+		case "{name}":
+			return tokens.Name
+		case "{ext}":
+			return tokens.Ext
+		case "{jpgext}":
+			lower := strings.ToLower(tokens.Ext)
+			if lower == "jpg" || lower == "jpeg" {
+				return tokens.Ext
+			}
+			return "jpg"
+		case "{dir}":
+			return tokens.Dir
+		case "{profile}":
+			return tokens.Profile
+		case "{distance}":
+			return strconv.FormatFloat(tokens.Distance, 'f', -1, 64)
+		case "{date}":
+			return tokens.Date.Format("2006-01-02")
+		case "{yyyy}":
+			return captureDate(match, "2006")
+		case "{mm}":
+			return captureDate(match, "01")
+		case "{dd}":
+			return captureDate(match, "02")
+		case "{yyyy-mm-dd}":
+			return captureDate(match, "2006-01-02")
+		case "{camera}":
+			return capture(match, sanitizePathElement(tokens.Camera))
+		case "{lens}":
+			return capture(match, sanitizePathElement(tokens.Lens))
+		}
+		unknown = append(unknown, match)
+		return match
+	})
+	if len(unknown) > 0 {
+		return "", fmt.Errorf("unknown token(s) %s in template %q", strings.Join(unknown, ", "), template)
+	}
+	if len(unavailable) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrTokenUnavailable, strings.Join(unavailable, ", "))
+	}
+
+	rendered = filepath.Clean(filepath.FromSlash(rendered))
+	if rendered == "." || filepath.IsAbs(rendered) || rendered == ".." || strings.HasPrefix(rendered, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("template %q must render to a relative path, got %q", template, rendered)
+	}
+	return rendered, nil
+}
+
+// sanitizePathElement makes an EXIF value usable as a single folder name.
+func sanitizePathElement(value string) string {
+	return strings.TrimSpace(invalidPathChars.ReplaceAllString(value, "_"))
+}
+
+// ResolveOutputRoot returns the directory outputs are written to. An empty root
+// means next to the source (base), a relative root is resolved against base.
+func ResolveOutputRoot(root, base string) string {
+	if root == "" {
+		return filepath.Clean(base)
+	}
+	if filepath.IsAbs(root) {
+		return filepath.Clean(root)
+	}
+	return filepath.Join(base, root)
+}
@@ -0,0 +1,80 @@
+package filehandling
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	date := time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC)
+	jpegTokens := NewNameTokens(filepath.Join("photos", "2024", "IMG_0001.JPG"), "web", 1.5, date)
+	pngTokens := NewNameTokens(filepath.Join("photos", "logo.png"), "web", 1.5, date)
+	exifTokens := jpegTokens
+	exifTokens.CaptureDate = time.Date(2023, 12, 24, 18, 30, 0, 0, time.UTC)
+	exifTokens.Camera = "ILCE-7M3"
+	exifTokens.Lens = "FE 24-105mm F4 G OSS"
+
+	tests := []struct {
+		name     string
+		template string
+		tokens   NameTokens
+		want     string
+		wantErr  bool
+	}{
+		{name: "sibling default", template: "{name}.jpegli.jpg", tokens: jpegTokens, want: "IMG_0001.jpegli.jpg"},
+		{name: "override default", template: "{name}.{ext}.jpg", tokens: pngTokens, want: "logo.png.jpg"},
+		{name: "jpgext keeps jpeg extension", template: "{name}.{jpgext}", tokens: jpegTokens, want: "IMG_0001.JPG"},
+		{name: "jpgext for non jpeg", template: "{name}.{jpgext}", tokens: pngTokens, want: "logo.jpg"},
+		{name: "all tokens", template: "{dir}/{profile}-{distance}-{date}/{name}.jpg", tokens: jpegTokens, want: filepath.Join("2024", "web-1.5-2024-03-09", "IMG_0001.jpg")},
+		{name: "capture date layout", template: "{yyyy}/{yyyy-mm-dd}", tokens: exifTokens, want: filepath.Join("2023", "2023-12-24")},
+		{name: "camera and lens are sanitized", template: "{camera}/{lens}/{mm}-{dd}", tokens: exifTokens, want: filepath.Join("ILCE-7M3", "FE 24-105mm F4 G OSS", "12-24")},
+		{name: "missing capture date", template: "{yyyy}", tokens: jpegTokens, wantErr: true},
+		{name: "missing camera", template: "{camera}", tokens: jpegTokens, wantErr: true},
+		{name: "missing camera after other tokens", template: "{name}-{camera}.jpg", tokens: jpegTokens, wantErr: true},
+		{name: "unknown token", template: "{name}-{foo}.jpg", tokens: exifTokens, wantErr: true},
+		{name: "unknown token with digits", template: "{name2}.jpg", tokens: exifTokens, wantErr: true},
+		{name: "unknown token with underscore", template: "{camera_model}/{name}.jpg", tokens: exifTokens, wantErr: true},
+		{name: "unknown capitalized token", template: "{Name}.jpg", tokens: exifTokens, wantErr: true},
+		{name: "empty template", template: "", tokens: jpegTokens, wantErr: true},
+		{name: "escaping template", template: "../{name}.jpg", tokens: jpegTokens, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderTemplate(tt.template, tt.tokens)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RenderTemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("RenderTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveOutputRoot(t *testing.T) {
+	base := filepath.Join("photos", "2024")
+	absolute, err := filepath.Abs(filepath.Join("exports", "web"))
+	if err != nil {
+		t.Fatalf("Failed to build absolute path: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		root string
+		want string
+	}{
+		{name: "empty root is next to source", root: "", want: base},
+		{name: "relative root", root: "optimized", want: filepath.Join(base, "optimized")},
+		{name: "absolute root with trailing separator", root: absolute + string(filepath.Separator), want: absolute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResolveOutputRoot(tt.root, base); got != tt.want {
+				t.Errorf("ResolveOutputRoot(%q) = %q, want %q", tt.root, got, tt.want)
+			}
+		})
+	}
+}
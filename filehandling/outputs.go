@@ -0,0 +1,112 @@
+package filehandling
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// OutputExistsPolicy decides what happens when an output path is already taken,
+// either by an existing file or by another output of the same run.
+type OutputExistsPolicy string
+
+const (
+	// OutputExistsSkip leaves the existing file alone and skips the source
+	OutputExistsSkip OutputExistsPolicy = "skip"
+	// OutputExistsOverwrite replaces existing files. Two sources of the same run
+	// never share an output, the later one is renamed instead.
+	OutputExistsOverwrite OutputExistsPolicy = "overwrite"
+	// OutputExistsRename appends a numeric suffix, e.g. "photo (1).jpg"
+	OutputExistsRename OutputExistsPolicy = "rename"
+	// OutputExistsFail aborts the run before anything is encoded
+	OutputExistsFail OutputExistsPolicy = "fail"
+)
+
+// PlannedOutput maps a source file to the output path it will be written to.
+type PlannedOutput struct {
+	Source string
+	Target string
+	// Skip is set if the source must not be converted, SkipReason explains why
+	Skip       bool
+	SkipReason string
+}
+
+// FileExists reports whether a file or directory exists at path.
+func FileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// ResolveOutputCollisions applies policy to every planned output whose target
+// already exists (according to exists) or is shared with an earlier output of
+// the same run. Outputs replacing their own source are never collisions.
+func ResolveOutputCollisions(planned []PlannedOutput, policy OutputExistsPolicy, exists func(string) bool) ([]PlannedOutput, error) {
+	switch policy {
+	case "":
+		policy = OutputExistsOverwrite
+	case OutputExistsSkip, OutputExistsOverwrite, OutputExistsRename, OutputExistsFail:
+	default:
+		return nil, fmt.Errorf("unknown output exists policy: %q", policy)
+	}
+
+	resolved := make([]PlannedOutput, 0, len(planned))
+	taken := map[string]string{}
+	isTaken := func(path string) bool {
+		_, ok := taken[pathKey(path)]
+		return ok
+	}
+
+	for _, out := range planned {
+		if out.Skip {
+			resolved = append(resolved, out)
+			continue
+		}
+
+		inPlace := pathKey(out.Target) == pathKey(out.Source)
+		sharedWith, shared := taken[pathKey(out.Target)]
+		existing := !inPlace && exists(out.Target)
+
+		if shared || existing {
+			switch {
+			case policy == OutputExistsFail && shared:
+				return nil, fmt.Errorf("%s and %s would both be written to %s", sharedWith, out.Source, out.Target)
+			case policy == OutputExistsFail:
+				return nil, fmt.Errorf("output for %s already exists: %s", out.Source, out.Target)
+			case policy == OutputExistsSkip:
+				out.Skip = true
+				out.SkipReason = fmt.Sprintf("output already exists: %s", out.Target)
+				resolved = append(resolved, out)
+				continue
+			case policy == OutputExistsRename || shared:
+				out.Target = numberedPath(out.Target, func(p string) bool { return isTaken(p) || exists(p) })
+			}
+		}
+
+		taken[pathKey(out.Target)] = out.Source
+		resolved = append(resolved, out)
+	}
+	return resolved, nil
+}
+
+// numberedPath returns the first "name (n).ext" variant of path that is not taken.
+func numberedPath(path string, taken func(string) bool) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if !taken(candidate) {
+			return candidate
+		}
+	}
+}
+
+// pathKey normalizes a path for comparisons, file names are case-insensitive on Windows.
+func pathKey(path string) string {
+	key := filepath.Clean(path)
+	if runtime.GOOS == "windows" {
+		key = strings.ToLower(key)
+	}
+	return key
+}
@@ -0,0 +1,86 @@
+package filehandling
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveOutputCollisions(t *testing.T) {
+	dir := filepath.Join("photos", "out")
+	existing := filepath.Join(dir, "existing.jpg")
+	exists := func(path string) bool { return path == existing }
+
+	planned := []PlannedOutput{
+		{Source: filepath.Join("photos", "a.png"), Target: filepath.Join(dir, "a.jpg")},
+		{Source: filepath.Join("photos", "a.jpg"), Target: filepath.Join(dir, "a.jpg")},
+		{Source: filepath.Join("photos", "existing.jpg"), Target: existing},
+		{Source: filepath.Join("photos", "done.jpg"), Skip: true, SkipReason: "already processed"},
+		{Source: filepath.Join(dir, "existing.jpg"), Target: existing},
+	}
+
+	tests := []struct {
+		name        string
+		policy      OutputExistsPolicy
+		wantTargets []string
+		wantSkipped []bool
+		wantErr     bool
+	}{
+		{
+			name:        "overwrite replaces existing files but renames shared outputs",
+			policy:      OutputExistsOverwrite,
+			wantTargets: []string{filepath.Join(dir, "a.jpg"), filepath.Join(dir, "a (1).jpg"), existing, "", filepath.Join(dir, "existing (1).jpg")},
+			wantSkipped: []bool{false, false, false, true, false},
+		},
+		{
+			name:        "empty policy defaults to overwrite",
+			policy:      "",
+			wantTargets: []string{filepath.Join(dir, "a.jpg"), filepath.Join(dir, "a (1).jpg"), existing, "", filepath.Join(dir, "existing (1).jpg")},
+			wantSkipped: []bool{false, false, false, true, false},
+		},
+		{
+			name:        "rename appends numeric suffix",
+			policy:      OutputExistsRename,
+			wantTargets: []string{filepath.Join(dir, "a.jpg"), filepath.Join(dir, "a (1).jpg"), filepath.Join(dir, "existing (1).jpg"), "", existing},
+			wantSkipped: []bool{false, false, false, true, false},
+		},
+		{
+			name:        "skip leaves taken outputs alone",
+			policy:      OutputExistsSkip,
+			wantTargets: []string{filepath.Join(dir, "a.jpg"), filepath.Join(dir, "a.jpg"), existing, "", existing},
+			wantSkipped: []bool{false, true, true, true, false},
+		},
+		{
+			name:    "fail aborts on first collision",
+			policy:  OutputExistsFail,
+			wantErr: true,
+		},
+		{
+			name:    "unknown policy",
+			policy:  "unknown",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveOutputCollisions(planned, tt.policy, exists)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveOutputCollisions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(planned) {
+				t.Fatalf("Expected %d outputs, got %d", len(planned), len(got))
+			}
+			for i, out := range got {
+				if out.Target != tt.wantTargets[i] {
+					t.Errorf("Output %d: Target = %s, want %s", i, out.Target, tt.wantTargets[i])
+				}
+				if out.Skip != tt.wantSkipped[i] {
+					t.Errorf("Output %d: Skip = %v, want %v", i, out.Skip, tt.wantSkipped[i])
+				}
+			}
+		})
+	}
+}
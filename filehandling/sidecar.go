@@ -0,0 +1,48 @@
+package filehandling
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FindSidecars returns the XMP sidecar files of sourcePath, in both the
+// "name.xmp" (Lightroom) and "name.jpg.xmp" (darktable) naming style.
+func FindSidecars(sourcePath string) []string {
+	base := strings.TrimSuffix(sourcePath, filepath.Ext(sourcePath))
+	candidates := []string{base + ".xmp", base + ".XMP", sourcePath + ".xmp", sourcePath + ".XMP"}
+
+	var sidecars []string
+	var found []os.FileInfo
+	for _, candidate := range candidates {
+		info, err := os.Stat(candidate)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		// Case-insensitive filesystems report the same file for both spellings
+		duplicate := false
+		for _, other := range found {
+			if os.SameFile(info, other) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			found = append(found, info)
+			sidecars = append(sidecars, candidate)
+		}
+	}
+	return sidecars
+}
+
+// SidecarPath returns the path the sidecar of sourcePath must have to belong to
+// outputPath, keeping the naming style of the original sidecar.
+func SidecarPath(sidecar, sourcePath, outputPath string) string {
+	sidecarExt := filepath.Ext(sidecar)
+	if strings.EqualFold(strings.TrimSuffix(sidecar, sidecarExt), sourcePath) {
+		// name.jpg.xmp style
+		return outputPath + sidecarExt
+	}
+	// name.xmp style
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + sidecarExt
+}
@@ -0,0 +1,50 @@
+package filehandling
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindSidecars(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "IMG_0001.jpg")
+	for _, name := range []string{"IMG_0001.jpg", "IMG_0001.xmp", "IMG_0001.jpg.xmp", "IMG_0002.xmp"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	got := FindSidecars(source)
+	want := []string{filepath.Join(dir, "IMG_0001.xmp"), filepath.Join(dir, "IMG_0001.jpg.xmp")}
+	if len(got) != len(want) {
+		t.Fatalf("FindSidecars() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("FindSidecars()[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSidecarPath(t *testing.T) {
+	source := filepath.Join("photos", "IMG_0001.png")
+	output := filepath.Join("out", "IMG_0001.png.jpg")
+
+	tests := []struct {
+		name    string
+		sidecar string
+		want    string
+	}{
+		{name: "lightroom style", sidecar: filepath.Join("photos", "IMG_0001.xmp"), want: filepath.Join("out", "IMG_0001.png.xmp")},
+		{name: "darktable style", sidecar: filepath.Join("photos", "IMG_0001.png.xmp"), want: filepath.Join("out", "IMG_0001.png.jpg.xmp")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SidecarPath(tt.sidecar, source, output); got != tt.want {
+				t.Errorf("SidecarPath() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
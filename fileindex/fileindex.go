@@ -0,0 +1,200 @@
+// Package fileindex caches the metadata read from files between runs, so
+// unchanged files are planned without spawning exiftool.
+package fileindex
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dhcgn/jpegli-windows-explorer-extension/convert"
+)
+
+// FileName is the name of the index file in the app folder.
+const FileName = "index.json"
+
+// formatVersion is increased when the stored data changes incompatibly, an
+// index of another version is discarded.
+const formatVersion = 1
+
+// Entry is the cached metadata of a file, valid as long as the file has the
+// recorded size and content.
+type Entry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	// Hash is the sha256 of the content, used when only the time changed
+	Hash     string               `json:"hash"`
+	Metadata convert.FileMetadata `json:"metadata"`
+}
+
+type indexFile struct {
+	Version int              `json:"version"`
+	Entries map[string]Entry `json:"entries"`
+}
+
+// Index maps file paths to entries. A nil *Index is valid and caches nothing.
+type Index struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]Entry
+	dirty   bool
+}
+
+// Open loads the index stored at path. A missing file yields an empty index.
+// An unreadable or outdated file also yields an empty index, together with an
+// error describing why it was discarded.
+func Open(path string) (*Index, error) {
+	idx := &Index{path: path, entries: map[string]Entry{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return idx, nil
+	}
+	if err != nil {
+		return idx, fmt.Errorf("error reading index %s: %w", path, err)
+	}
+	var stored indexFile
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return idx, fmt.Errorf("error parsing index %s: %w", path, err)
+	}
+	if stored.Version != formatVersion {
+		return idx, fmt.Errorf("index %s has version %d, expected %d", path, stored.Version, formatVersion)
+	}
+	if stored.Entries != nil {
+		idx.entries = stored.Entries
+	}
+	return idx, nil
+}
+
+// Lookup returns the cached metadata of path if the file is unchanged. Size
+// and modification time are compared first, if only the time differs the
+// content hash decides.
+func (i *Index) Lookup(path string) (convert.FileMetadata, bool) {
+	if i == nil {
+		return convert.FileMetadata{}, false
+	}
+	key := pathKey(path)
+	i.mu.Lock()
+	entry, ok := i.entries[key]
+	i.mu.Unlock()
+	if !ok {
+		return convert.FileMetadata{}, false
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Size() != entry.Size {
+		return convert.FileMetadata{}, false
+	}
+	if info.ModTime().Equal(entry.ModTime) {
+		return entry.Metadata, true
+	}
+	hash, err := convert.FileHash(path)
+	if err != nil || hash != entry.Hash {
+		return convert.FileMetadata{}, false
+	}
+	entry.ModTime = info.ModTime()
+	i.mu.Lock()
+	i.entries[key] = entry
+	i.dirty = true
+	i.mu.Unlock()
+	return entry.Metadata, true
+}
+
+// Metadata returns the metadata last recorded for path, without checking if
+// the file changed since.
+func (i *Index) Metadata(path string) (convert.FileMetadata, bool) {
+	if i == nil {
+		return convert.FileMetadata{}, false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	entry, ok := i.entries[pathKey(path)]
+	return entry.Metadata, ok
+}
+
+// Record stores metadata for the current content of path.
+func (i *Index) Record(path string, metadata convert.FileMetadata) error {
+	if i == nil {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	hash, err := convert.FileHash(path)
+	if err != nil {
+		return err
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.entries[pathKey(path)] = Entry{Size: info.Size(), ModTime: info.ModTime(), Hash: hash, Metadata: metadata}
+	i.dirty = true
+	return nil
+}
+
+// Forget removes the entry of path, e.g. after the file was deleted.
+func (i *Index) Forget(path string) {
+	if i == nil {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, ok := i.entries[pathKey(path)]; ok {
+		delete(i.entries, pathKey(path))
+		i.dirty = true
+	}
+}
+
+// Save writes the index if it changed. The file is replaced atomically, so an
+// interrupted run never leaves a truncated index behind.
+func (i *Index) Save() error {
+	if i == nil {
+		return nil
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if !i.dirty {
+		return nil
+	}
+	data, err := json.Marshal(indexFile{Version: formatVersion, Entries: i.entries})
+	if err != nil {
+		return fmt.Errorf("error encoding index: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(i.path), 0755); err != nil {
+		return fmt.Errorf("error creating index folder: %w", err)
+	}
+	temp, err := os.CreateTemp(filepath.Dir(i.path), ".index-*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating temporary index: %w", err)
+	}
+	tempPath := temp.Name()
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempPath, i.path)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("error writing index %s: %w", i.path, err)
+	}
+	i.dirty = false
+	return nil
+}
+
+// pathKey returns the absolute, cleaned path, case-insensitive on Windows.
+func pathKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.Clean(path)
+	if runtime.GOOS == "windows" {
+		return strings.ToLower(path)
+	}
+	return path
+}
@@ -0,0 +1,94 @@
+package fileindex
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dhcgn/jpegli-windows-explorer-extension/convert"
+)
+
+func TestIndexLookup(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "photo.jpg")
+	if err := os.WriteFile(file, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	metadata := convert.FileMetadata{OptimizedBy: "app 1.0", Distance: 0.5, DistanceKnown: true, Camera: "X100V"}
+
+	idx, err := Open(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if _, ok := idx.Lookup(file); ok {
+		t.Fatal("Lookup() hit on empty index")
+	}
+	if err := idx.Record(file, metadata); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if err := idx.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	reopened, err := Open(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	got, ok := reopened.Lookup(file)
+	if !ok || got != metadata {
+		t.Fatalf("Lookup() = %+v, %v, want %+v, true", got, ok, metadata)
+	}
+
+	// Same content with a new time is still a hit
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Lookup(file); !ok {
+		t.Error("Lookup() missed after touching the file")
+	}
+
+	// Same size with other content is a miss
+	if err := os.WriteFile(file, []byte("modified"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Lookup(file); ok {
+		t.Error("Lookup() hit after changing the content")
+	}
+
+	reopened.Forget(file)
+	if _, ok := reopened.Metadata(file); ok {
+		t.Error("Metadata() found forgotten entry")
+	}
+}
+
+func TestOpenDiscardsInvalidIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := Open(path)
+	if err == nil {
+		t.Error("Open() expected error for invalid index")
+	}
+	if idx == nil {
+		t.Fatal("Open() returned no index")
+	}
+	if _, ok := idx.Metadata("photo.jpg"); ok {
+		t.Error("invalid index has entries")
+	}
+}
+
+func TestNilIndex(t *testing.T) {
+	var idx *Index
+	if _, ok := idx.Lookup("photo.jpg"); ok {
+		t.Error("nil index Lookup() hit")
+	}
+	if err := idx.Record("photo.jpg", convert.FileMetadata{}); err != nil {
+		t.Errorf("nil index Record() error = %v", err)
+	}
+	if err := idx.Save(); err != nil {
+		t.Errorf("nil index Save() error = %v", err)
+	}
+}
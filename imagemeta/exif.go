@@ -0,0 +1,104 @@
+package imagemeta
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// EXIF tags read by ParseExif.
+const (
+	exifTagOrientation     = 0x0112
+	exifTagThumbnailOffset = 0x0201
+	exifTypeShort          = 3
+	exifFieldSize          = 12
+)
+
+// ExifInfo holds the EXIF values the converter acts on.
+type ExifInfo struct {
+	// Orientation is the EXIF orientation, 1 (upright) to 8, 0 if not present
+	Orientation int
+	// Thumbnail is set if IFD1 holds an embedded JPEG thumbnail
+	Thumbnail bool
+}
+
+// ReadExif reads the EXIF of the JPEG, PNG or JPEG XL file at path. Files
+// without EXIF yield an empty result.
+func ReadExif(path string) (ExifInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ExifInfo{}, err
+	}
+	if !bytes.HasPrefix(data, []byte{0xff, markerSOI}) {
+		m, err := Parse(data)
+		if err != nil || len(m.Exif) == 0 {
+			return ExifInfo{}, err
+		}
+		return ParseExif(m.Exif)
+	}
+	segments, _, err := splitJPEG(data)
+	if err != nil {
+		return ExifInfo{}, err
+	}
+	for _, s := range segments {
+		if s.kind() == segmentExif {
+			return ParseExif(s.Data[len(exifHeader):])
+		}
+	}
+	return ExifInfo{}, nil
+}
+
+// ParseExif reads the orientation of IFD0 and the thumbnail of IFD1 from an
+// EXIF TIFF structure.
+func ParseExif(tiff []byte) (ExifInfo, error) {
+	order, ifd0, err := tiffHeader(tiff)
+	if err != nil {
+		return ExifInfo{}, err
+	}
+	var info ExifInfo
+	fields, next, err := ifdFields(tiff, ifd0)
+	if err != nil {
+		return ExifInfo{}, err
+	}
+	for _, field := range fields {
+		if order.Uint16(field) == exifTagOrientation && order.Uint16(field[2:]) == exifTypeShort {
+			info.Orientation = int(order.Uint16(field[8:]))
+		}
+	}
+	if next == 0 {
+		return info, nil
+	}
+	fields, _, err = ifdFields(tiff, next)
+	if err != nil {
+		return info, fmt.Errorf("exif ifd1: %w", err)
+	}
+	for _, field := range fields {
+		if order.Uint16(field) == exifTagThumbnailOffset {
+			info.Thumbnail = true
+		}
+	}
+	return info, nil
+}
+
+// ifdFields returns the 12 byte fields of the IFD at offset and the offset of
+// the next IFD.
+func ifdFields(tiff []byte, offset int) ([][]byte, int, error) {
+	order, _, err := tiffHeader(tiff)
+	if err != nil {
+		return nil, 0, err
+	}
+	if offset < 8 || offset+2 > len(tiff) {
+		return nil, 0, fmt.Errorf("ifd offset %d: %w", offset, errTruncated)
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	end := offset + 2 + count*exifFieldSize
+	if end+4 > len(tiff) {
+		return nil, 0, fmt.Errorf("ifd: %w", errTruncated)
+	}
+	fields := make([][]byte, count)
+	for i := range fields {
+		start := offset + 2 + i*exifFieldSize
+		fields[i] = tiff[start : start+exifFieldSize]
+	}
+	return fields, int(order.Uint32(tiff[end:])), nil
+}
@@ -0,0 +1,76 @@
+package imagemeta
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testOrientationExif builds a big endian EXIF structure with an orientation in IFD0 and,
+// if thumbnail is set, an IFD1 pointing to a thumbnail.
+func testOrientationExif(orientation uint16, thumbnail bool) []byte {
+	be := binary.BigEndian
+	tiff := be.AppendUint32([]byte("MM\x00\x2a"), 8)
+	tiff = be.AppendUint16(tiff, 1)
+	tiff = be.AppendUint16(be.AppendUint16(tiff, exifTagOrientation), exifTypeShort)
+	tiff = be.AppendUint32(tiff, 1)
+	tiff = be.AppendUint32(tiff, uint32(orientation)<<16)
+	if !thumbnail {
+		return be.AppendUint32(tiff, 0)
+	}
+	tiff = be.AppendUint32(tiff, uint32(len(tiff)+4))
+	tiff = be.AppendUint16(tiff, 1)
+	tiff = be.AppendUint16(be.AppendUint16(tiff, exifTagThumbnailOffset), 4)
+	tiff = be.AppendUint32(be.AppendUint32(tiff, 1), 0)
+	return be.AppendUint32(tiff, 0)
+}
+
+func TestParseExif(t *testing.T) {
+	tests := []struct {
+		name string
+		exif []byte
+		want ExifInfo
+	}{
+		{name: "orientation", exif: testOrientationExif(6, false), want: ExifInfo{Orientation: 6}},
+		{name: "thumbnail", exif: testOrientationExif(1, true), want: ExifInfo{Orientation: 1, Thumbnail: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseExif(tt.exif)
+			if err != nil {
+				t.Fatalf("ParseExif() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseExif() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseExif(testOrientationExif(6, false)[:12]); err == nil {
+		t.Error("ParseExif() of truncated data, want error")
+	}
+}
+
+func TestReadExif(t *testing.T) {
+	data, _, err := InsertJPEG(testJPEG(t), Metadata{Exif: testOrientationExif(8, true)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadExif(path)
+	if err != nil {
+		t.Fatalf("ReadExif() error = %v", err)
+	}
+	if want := (ExifInfo{Orientation: 8, Thumbnail: true}); got != want {
+		t.Errorf("ReadExif() = %+v, want %+v", got, want)
+	}
+
+	got, err = ReadExif(filepath.Join("testdata", "png_text.png"))
+	if err != nil || got != (ExifInfo{}) {
+		t.Errorf("ReadExif(png without exif) = %+v, %v, want empty", got, err)
+	}
+}
@@ -0,0 +1,145 @@
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// JPEGFrame describes how a JPEG is coded, read from its SOF and Adobe APP14
+// segments.
+type JPEGFrame struct {
+	// Marker is the SOF marker, e.g. 0xc0 for baseline
+	Marker byte
+	// Precision is the sample precision in bits, 8 for almost all files
+	Precision     int
+	Width, Height int
+	Components    int
+	// AdobeTransform is the color transform of an Adobe APP14 segment, -1 if
+	// there is none
+	AdobeTransform int
+}
+
+// ReadJPEGFrame reads the frame header of the JPEG at path.
+func ReadJPEGFrame(path string) (JPEGFrame, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return JPEGFrame{}, err
+	}
+	return ParseJPEGFrame(data)
+}
+
+// ParseJPEGFrame reads the frame header of a JPEG.
+func ParseJPEGFrame(data []byte) (JPEGFrame, error) {
+	segments, _, err := splitJPEG(data)
+	if err != nil {
+		return JPEGFrame{}, err
+	}
+	frame := JPEGFrame{AdobeTransform: -1}
+	found := false
+	for _, s := range segments {
+		switch {
+		case s.Marker == markerAPP0+14 && bytes.HasPrefix(s.Data, adobeHeader) && len(s.Data) > adobeTransformOffset:
+			frame.AdobeTransform = int(s.Data[adobeTransformOffset])
+		case bytes.IndexByte(markerSOF, s.Marker) >= 0 && !found:
+			if len(s.Data) < 6 {
+				return JPEGFrame{}, fmt.Errorf("jpeg frame header: %w", errTruncated)
+			}
+			found = true
+			frame.Marker = s.Marker
+			frame.Precision = int(s.Data[0])
+			frame.Height = int(binary.BigEndian.Uint16(s.Data[1:]))
+			frame.Width = int(binary.BigEndian.Uint16(s.Data[3:]))
+			frame.Components = int(s.Data[5])
+		}
+	}
+	if !found {
+		return JPEGFrame{}, fmt.Errorf("jpeg has no frame header")
+	}
+	return frame, nil
+}
+
+// Arithmetic reports whether the image is arithmetic coded.
+func (f JPEGFrame) Arithmetic() bool {
+	return f.Marker >= 0xc9
+}
+
+// Lossless reports whether the image uses the lossless process.
+func (f JPEGFrame) Lossless() bool {
+	return f.Marker&0x03 == 0x03
+}
+
+// Hierarchical reports whether the image is coded in several resolutions.
+func (f JPEGFrame) Hierarchical() bool {
+	return f.Marker&0x07 >= 0x05
+}
+
+// Progressive reports whether the image is progressive.
+func (f JPEGFrame) Progressive() bool {
+	return f.Marker&0x03 == 0x02
+}
+
+// ColorModel names the color model as the decoder interprets it.
+func (f JPEGFrame) ColorModel() string {
+	switch f.Components {
+	case 1:
+		return "Gray"
+	case 3:
+		if f.AdobeTransform == 0 {
+			return "RGB"
+		}
+		return "YCbCr"
+	case 4:
+		if f.AdobeTransform == 2 {
+			return "YCCK"
+		}
+		return "CMYK"
+	}
+	return fmt.Sprintf("%d components", f.Components)
+}
+
+// Unusual lists why the image may not round-trip through the encoder: a CMYK
+// or YCCK color model, a precision other than 8 bits, arithmetic, lossless or
+// hierarchical coding. It is empty for ordinary JPEGs.
+func (f JPEGFrame) Unusual() []string {
+	var reasons []string
+	if f.Components != 1 && f.Components != 3 {
+		reasons = append(reasons, f.ColorModel())
+	}
+	if f.Precision != 8 {
+		reasons = append(reasons, fmt.Sprintf("%d-bit", f.Precision))
+	}
+	if f.Arithmetic() {
+		reasons = append(reasons, "arithmetic coding")
+	}
+	if f.Lossless() {
+		reasons = append(reasons, "lossless")
+	}
+	if f.Hierarchical() {
+		reasons = append(reasons, "hierarchical")
+	}
+	return reasons
+}
+
+// String describes the frame for reports, e.g. "CMYK, 8-bit, baseline".
+func (f JPEGFrame) String() string {
+	process := "baseline"
+	switch {
+	case f.Lossless():
+		process = "lossless"
+	case f.Progressive():
+		process = "progressive"
+	case f.Marker&0x0f != 0x00:
+		process = "extended"
+	}
+	parts := []string{f.ColorModel(), fmt.Sprintf("%d-bit", f.Precision), process}
+	if f.Arithmetic() {
+		parts = append(parts, "arithmetic")
+	}
+	if f.Hierarchical() {
+		parts = append(parts, "hierarchical")
+	}
+	return strings.Join(parts, ", ")
+}
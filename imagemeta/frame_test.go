@@ -0,0 +1,66 @@
+package imagemeta
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testFrameHeader returns the header of a JPEG up to its first scan.
+func testFrameHeader(sof byte, precision byte, components byte, adobeTransform int) []byte {
+	out := []byte{0xff, markerSOI}
+	if adobeTransform >= 0 {
+		out = appendSegment(out, segment{Marker: markerAPP0 + 14, Data: []byte{'A', 'd', 'o', 'b', 'e', 0, 100, 0, 0, 0, 0, byte(adobeTransform)}})
+	}
+	sofData := []byte{precision, 0, 16, 0, 32, components}
+	for i := byte(0); i < components; i++ {
+		sofData = append(sofData, i+1, 0x11, 0)
+	}
+	out = appendSegment(out, segment{Marker: sof, Data: sofData})
+	return append(out, 0xff, markerSOS, 0, 2)
+}
+
+func TestParseJPEGFrame(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		wantString  string
+		wantUnusual []string
+	}{
+		{name: "gray baseline", data: testJPEG(t), wantString: "Gray, 8-bit, baseline"},
+		{name: "ycbcr progressive", data: testFrameHeader(0xc2, 8, 3, -1), wantString: "YCbCr, 8-bit, progressive"},
+		{name: "adobe rgb", data: testFrameHeader(0xc0, 8, 3, 0), wantString: "RGB, 8-bit, baseline"},
+		{name: "cmyk", data: testFrameHeader(0xc0, 8, 4, 0), wantString: "CMYK, 8-bit, baseline", wantUnusual: []string{"CMYK"}},
+		{name: "ycck progressive", data: testFrameHeader(0xc2, 8, 4, 2), wantString: "YCCK, 8-bit, progressive", wantUnusual: []string{"YCCK"}},
+		{name: "12-bit", data: testFrameHeader(0xc1, 12, 3, -1), wantString: "YCbCr, 12-bit, extended", wantUnusual: []string{"12-bit"}},
+		{name: "arithmetic", data: testFrameHeader(0xc9, 8, 3, -1), wantString: "YCbCr, 8-bit, extended, arithmetic", wantUnusual: []string{"arithmetic coding"}},
+		{name: "lossless", data: testFrameHeader(0xc3, 16, 1, -1), wantString: "Gray, 16-bit, lossless", wantUnusual: []string{"16-bit", "lossless"}},
+		{name: "hierarchical", data: testFrameHeader(0xc5, 8, 3, -1), wantString: "YCbCr, 8-bit, extended, hierarchical", wantUnusual: []string{"hierarchical"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := ParseJPEGFrame(tt.data)
+			if err != nil {
+				t.Fatalf("ParseJPEGFrame() error = %v", err)
+			}
+			if got := frame.String(); got != tt.wantString {
+				t.Errorf("String() = %q, want %q", got, tt.wantString)
+			}
+			if got := frame.Unusual(); !reflect.DeepEqual(got, tt.wantUnusual) {
+				t.Errorf("Unusual() = %q, want %q", got, tt.wantUnusual)
+			}
+		})
+	}
+}
+
+func TestParseJPEGFrameDimensions(t *testing.T) {
+	frame, err := ParseJPEGFrame(testFrameHeader(0xc0, 8, 3, -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.Width != 32 || frame.Height != 16 || frame.Components != 3 || frame.AdobeTransform != -1 {
+		t.Errorf("ParseJPEGFrame() = %+v", frame)
+	}
+	if _, err := ParseJPEGFrame([]byte{0xff, markerSOI, 0xff, markerSOS, 0, 2}); err == nil {
+		t.Error("ParseJPEGFrame() without SOF, want error")
+	}
+}
@@ -0,0 +1,128 @@
+package imagemeta
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// GIF block introducers and extension labels.
+const (
+	gifExtension      = 0x21
+	gifImage          = 0x2c
+	gifTrailer        = 0x3b
+	gifCommentLabel   = 0xfe
+	gifApplication    = 0xff
+	gifColorTableFlag = 0x80
+)
+
+// gifXMPIdentifier is the application identifier and authentication code of
+// an XMP application extension.
+var gifXMPIdentifier = []byte("XMP DataXMP")
+
+// gifXMPTrailerSize is the size of the "magic trailer" after XMP data: 0x01,
+// the bytes 0xff down to 0x00 and the block terminator.
+const gifXMPTrailerSize = 1 + 256 + 1
+
+// parseGIF reads comment extensions as "Comment" text and XMP application
+// extensions, and counts the frames. Image data is skipped.
+func parseGIF(data []byte) (Metadata, error) {
+	var m Metadata
+	if len(data) < 13 {
+		return m, errTruncated
+	}
+	pos := 13
+	if flags := data[10]; flags&gifColorTableFlag != 0 {
+		pos += 3 << (flags&0x07 + 1)
+	}
+
+	frames := 0
+	for pos < len(data) {
+		switch data[pos] {
+		case gifTrailer:
+			if frames > 1 {
+				m.Frames = frames
+			}
+			return m, nil
+		case gifImage:
+			frames++
+			if pos+10 > len(data) {
+				return m, errTruncated
+			}
+			flags := data[pos+9]
+			pos += 10
+			if flags&gifColorTableFlag != 0 {
+				pos += 3 << (flags&0x07 + 1)
+			}
+			// LZW minimum code size, then the image data sub-blocks
+			pos++
+			var err error
+			if _, pos, err = gifSubBlocks(data, pos); err != nil {
+				return m, err
+			}
+		case gifExtension:
+			if pos+2 > len(data) {
+				return m, errTruncated
+			}
+			label := data[pos+1]
+			pos += 2
+			var err error
+			switch {
+			case label == gifCommentLabel:
+				var comment []byte
+				if comment, pos, err = gifSubBlocks(data, pos); err != nil {
+					return m, err
+				}
+				m.Text = append(m.Text, TextEntry{Keyword: "Comment", Text: latin1ToUTF8(comment)})
+			case label == gifApplication && gifIsXMP(data, pos):
+				pos, err = m.readGIFXMP(data, pos+1+len(gifXMPIdentifier))
+				if err != nil {
+					return m, err
+				}
+			default:
+				if _, pos, err = gifSubBlocks(data, pos); err != nil {
+					return m, err
+				}
+			}
+		default:
+			return m, fmt.Errorf("gif: unknown block 0x%02x at offset %d", data[pos], pos)
+		}
+	}
+	return m, errTruncated
+}
+
+func gifIsXMP(data []byte, pos int) bool {
+	return pos < len(data) && int(data[pos]) == len(gifXMPIdentifier) &&
+		bytes.HasPrefix(data[pos+1:], gifXMPIdentifier)
+}
+
+// readGIFXMP reads XMP stored raw instead of in sub-blocks, which XMP allows
+// because the packet never contains the byte 0x01 that starts the trailer.
+func (m *Metadata) readGIFXMP(data []byte, pos int) (int, error) {
+	end := bytes.IndexByte(data[pos:], 0x01)
+	if end < 0 || pos+end+gifXMPTrailerSize > len(data) {
+		return pos, fmt.Errorf("gif xmp: %w", errTruncated)
+	}
+	m.XMP = append([]byte(nil), data[pos:pos+end]...)
+	return pos + end + gifXMPTrailerSize, nil
+}
+
+// gifSubBlocks returns the concatenated data of the sub-blocks at pos and the
+// position after the block terminator.
+func gifSubBlocks(data []byte, pos int) ([]byte, int, error) {
+	var content []byte
+	for {
+		if pos >= len(data) {
+			return nil, pos, errTruncated
+		}
+		size := int(data[pos])
+		pos++
+		if size == 0 {
+			return content, pos, nil
+		}
+		if pos+size > len(data) {
+			return nil, pos, errTruncated
+		}
+		content = append(content, data[pos:pos+size]...)
+		pos += size
+	}
+}
@@ -0,0 +1,103 @@
+// Package imagemeta extracts metadata from PNG, GIF and JPEG XL files and
+// writes it as the equivalent EXIF, XMP, ICC and comment segments into a JPEG.
+//
+// exiftool copies most of these tags itself, but drops or misplaces several
+// of them (PNG text chunks without a JPEG counterpart, ImageMagick raw
+// profiles, GIF XMP, ...). Reading them natively makes the result independent
+// of the exiftool version.
+package imagemeta
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Metadata is the metadata found in a source image.
+type Metadata struct {
+	// Exif is a TIFF structure as stored after the "Exif\0\0" header of a JPEG APP1 segment
+	Exif []byte
+	// XMP is the serialized XMP packet
+	XMP []byte
+	// ICC is the ICC color profile
+	ICC []byte
+	// Text holds the text entries, e.g. PNG tEXt chunks or GIF comments
+	Text []TextEntry
+	// SRGB is set by a PNG sRGB chunk, the image is in the sRGB color space
+	SRGB bool
+	// Gamma is the image gamma of a PNG gAMA chunk, e.g. 2.2, zero if not present
+	Gamma float64
+	// Frames is the number of frames of an animated GIF or APNG, zero for still images
+	Frames int
+	// Warnings describe metadata that was found but could not be read
+	Warnings []string
+}
+
+// TextEntry is a keyword and value pair.
+type TextEntry struct {
+	Keyword string
+	// Language is the language tag of an iTXt chunk, empty if unknown
+	Language string
+	Text     string
+}
+
+// Empty reports whether m holds nothing that can be written to a JPEG.
+func (m Metadata) Empty() bool {
+	return len(m.Exif) == 0 && len(m.XMP) == 0 && len(m.ICC) == 0 && len(m.Text) == 0 && !m.SRGB && m.Gamma == 0
+}
+
+var (
+	pngSignature          = []byte("\x89PNG\r\n\x1a\n")
+	jxlContainerSignature = []byte("\x00\x00\x00\x0cJXL \r\n\x87\n")
+	jxlCodestreamMarker   = []byte{0xff, 0x0a}
+)
+
+// Read extracts the metadata of the image at path. Formats without a native
+// reader, e.g. JPEG, yield empty metadata and no error.
+func Read(path string) (Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Metadata{}, err
+	}
+	defer f.Close()
+	// Check the signature first, so large files of other formats are not read
+	header := make([]byte, len(jxlContainerSignature))
+	n, err := io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return Metadata{}, err
+	}
+	if !supported(header[:n]) {
+		return Metadata{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Metadata{}, err
+	}
+	return Parse(data)
+}
+
+func supported(header []byte) bool {
+	return bytes.HasPrefix(header, pngSignature) || bytes.HasPrefix(header, []byte("GIF8")) ||
+		bytes.HasPrefix(header, jxlContainerSignature)
+}
+
+// Parse extracts the metadata of an encoded image, see Read.
+func Parse(data []byte) (Metadata, error) {
+	switch {
+	case bytes.HasPrefix(data, pngSignature):
+		return parsePNG(data[len(pngSignature):])
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return parseGIF(data)
+	case bytes.HasPrefix(data, jxlContainerSignature):
+		return parseJXL(data[len(jxlContainerSignature):])
+	case bytes.HasPrefix(data, jxlCodestreamMarker):
+		// A bare JPEG XL codestream carries no metadata boxes
+		return Metadata{}, nil
+	}
+	return Metadata{}, nil
+}
+
+// errTruncated is returned for structures ending before their declared length.
+var errTruncated = fmt.Errorf("truncated data: %w", io.ErrUnexpectedEOF)
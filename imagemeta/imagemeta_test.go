@@ -0,0 +1,157 @@
+package imagemeta
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Values of the corpus in testdata, see testdata/generate.go.
+const (
+	testExif = "MM\x00\x2a\x00\x00\x00\x08\x00\x00\x00\x00\x00\x00"
+	testICC  = "fake ICC profile for tests"
+)
+
+func TestReadCorpus(t *testing.T) {
+	tests := []struct {
+		file         string
+		wantExif     string
+		wantICC      string
+		wantXMPTitle bool
+		wantText     []TextEntry
+		wantSRGB     bool
+		wantGamma    bool
+		wantWarning  string
+	}{
+		{
+			file: "png_text.png",
+			wantText: []TextEntry{
+				{Keyword: "Title", Text: "Sunset"},
+				{Keyword: "Author", Text: "Jane Doe"},
+				{Keyword: "Copyright", Text: "© 2024 Jane Doe"},
+				{Keyword: "Creation Time", Text: "Fri, 17 May 2024 10:30:00 +0200"},
+				{Keyword: "Description", Text: "Evening at the lake"},
+				{Keyword: "Title", Language: "de", Text: "Sonnenuntergang"},
+				{Keyword: "Comment", Text: "Scanned from slide"},
+			},
+		},
+		{file: "png_exif_icc_gamma.png", wantExif: testExif, wantICC: testICC, wantGamma: true},
+		{file: "png_srgb.png", wantSRGB: true, wantGamma: true},
+		{
+			file:         "png_xmp.png",
+			wantXMPTitle: true,
+			wantText: []TextEntry{
+				{Keyword: "Title", Text: "Ignored, the packet has a title"},
+				{Keyword: "Author", Text: "Jane Doe"},
+			},
+		},
+		{file: "png_raw_profiles.png", wantExif: testExif, wantICC: testICC},
+		{file: "jxl_boxes.jxl", wantExif: testExif, wantXMPTitle: true, wantWarning: "brotli"},
+		{file: "gif_comment_xmp.gif", wantXMPTitle: true, wantText: []TextEntry{{Keyword: "Comment", Text: "Made with GIMP"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join("testdata", tt.file)
+			m, err := Read(path)
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if string(m.Exif) != tt.wantExif {
+				t.Errorf("Exif = %q, want %q", m.Exif, tt.wantExif)
+			}
+			if string(m.ICC) != tt.wantICC {
+				t.Errorf("ICC = %q, want %q", m.ICC, tt.wantICC)
+			}
+			if got := bytes.Contains(m.XMP, []byte("XMP title")); got != tt.wantXMPTitle {
+				t.Errorf("XMP = %q, want title %v", m.XMP, tt.wantXMPTitle)
+			}
+			if !reflect.DeepEqual(m.Text, tt.wantText) {
+				t.Errorf("Text = %+v, want %+v", m.Text, tt.wantText)
+			}
+			if m.SRGB != tt.wantSRGB {
+				t.Errorf("SRGB = %v, want %v", m.SRGB, tt.wantSRGB)
+			}
+			if (m.Gamma > 2.19 && m.Gamma < 2.21) != tt.wantGamma {
+				t.Errorf("Gamma = %v, want 2.2: %v", m.Gamma, tt.wantGamma)
+			}
+			if tt.wantWarning == "" && len(m.Warnings) > 0 {
+				t.Errorf("Warnings = %v, want none", m.Warnings)
+			}
+			if tt.wantWarning != "" && (len(m.Warnings) != 1 || !strings.Contains(m.Warnings[0], tt.wantWarning)) {
+				t.Errorf("Warnings = %v, want one containing %q", m.Warnings, tt.wantWarning)
+			}
+
+			// The corpus images must stay decodable, except the placeholder JPEG XL files
+			if filepath.Ext(tt.file) != ".jxl" {
+				f, err := os.Open(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				if _, _, err := image.Decode(f); err != nil {
+					t.Errorf("corpus image does not decode: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestParseUnsupportedFormat(t *testing.T) {
+	m, err := Parse([]byte{0xff, 0xd8, 0xff, 0xe0})
+	if err != nil || !m.Empty() {
+		t.Errorf("Parse(jpeg) = %+v, %v, want empty metadata", m, err)
+	}
+}
+
+func TestParseTruncatedPNG(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "png_text.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Parse(data[:60]); err == nil {
+		t.Error("Parse() of truncated png, want error")
+	}
+}
+
+func TestParseFrames(t *testing.T) {
+	var animated bytes.Buffer
+	frame := image.NewPaletted(image.Rect(0, 0, 2, 2), color.Palette{color.Black, color.White})
+	if err := gif.EncodeAll(&animated, &gif.GIF{Image: []*image.Paletted{frame, frame, frame}, Delay: []int{10, 10, 10}}); err != nil {
+		t.Fatal(err)
+	}
+	still, err := os.ReadFile(filepath.Join("testdata", "gif_comment_xmp.gif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ihdr := []byte("\x00\x00\x00\x0dIHDR\x00\x00\x00\x02\x00\x00\x00\x02\x08\x02\x00\x00\x00\x00\x00\x00\x00")
+	actl := []byte("\x00\x00\x00\x08acTL\x00\x00\x00\x05\x00\x00\x00\x00\x00\x00\x00\x00")
+	iend := []byte("\x00\x00\x00\x00IEND\x00\x00\x00\x00")
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{name: "animated gif", data: animated.Bytes(), want: 3},
+		{name: "still gif", data: still, want: 0},
+		{name: "apng", data: concat(pngSignature, ihdr, actl, iend), want: 5},
+		{name: "png", data: concat(pngSignature, ihdr, iend), want: 0},
+	}
+	for _, tt := range tests {
+		m, err := Parse(tt.data)
+		if err != nil {
+			t.Fatalf("%s: Parse() error = %v", tt.name, err)
+		}
+		if m.Frames != tt.want {
+			t.Errorf("%s: Frames = %d, want %d", tt.name, m.Frames, tt.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// JPEG markers.
+const (
+	markerSOI  = 0xd8
+	markerSOS  = 0xda
+	markerEOI  = 0xd9
+	markerAPP0 = 0xe0
+	markerAPP1 = 0xe1
+	markerAPP2 = 0xe2
+	markerCOM  = 0xfe
+)
+
+// maxSegmentData is the largest payload of a JPEG segment, the length field
+// counts itself.
+const maxSegmentData = 0xffff - 2
+
+var (
+	xmpHeader = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	iccHeader = []byte("ICC_PROFILE\x00")
+)
+
+// maxICCChunk is the ICC data per APP2 segment, after header, sequence number
+// and chunk count.
+const maxICCChunk = maxSegmentData - 14
+
+type segmentKind int
+
+const (
+	segmentOther segmentKind = iota
+	segmentExif
+	segmentXMP
+	segmentICC
+	segmentComment
+)
+
+type segment struct {
+	Marker byte
+	Data   []byte
+}
+
+func (s segment) kind() segmentKind {
+	switch {
+	case s.Marker == markerAPP1 && bytes.HasPrefix(s.Data, exifHeader):
+		return segmentExif
+	case s.Marker == markerAPP1 && bytes.HasPrefix(s.Data, xmpHeader):
+		return segmentXMP
+	case s.Marker == markerAPP2 && bytes.HasPrefix(s.Data, iccHeader):
+		return segmentICC
+	case s.Marker == markerCOM:
+		return segmentComment
+	}
+	return segmentOther
+}
+
+// WriteJPEG writes m into the JPEG file at path, see InsertJPEG.
+func WriteJPEG(path string, m Metadata) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	updated, warnings, err := InsertJPEG(data, m)
+	if err != nil {
+		return warnings, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return warnings, err
+	}
+	return warnings, os.WriteFile(path, updated, info.Mode().Perm())
+}
+
+// InsertJPEG returns jpegData with the EXIF, XMP, ICC and comment segments
+// built from m. Existing segments of a kind m provides are replaced, the image
+// data is copied unchanged. Metadata too large for a segment is skipped and
+// reported as warning.
+func InsertJPEG(jpegData []byte, m Metadata) ([]byte, []string, error) {
+	segments, imageData, err := splitJPEG(jpegData)
+	if err != nil {
+		return nil, nil, err
+	}
+	added, warnings := m.segments()
+	replaced := map[segmentKind]bool{}
+	for _, s := range added {
+		replaced[s.kind()] = true
+	}
+
+	out := make([]byte, 0, len(jpegData)+len(m.Exif)+len(m.XMP)+len(m.ICC)+1024)
+	out = append(out, 0xff, markerSOI)
+	// JFIF requires its APP0 segments to come first
+	i := 0
+	for ; i < len(segments) && segments[i].Marker == markerAPP0; i++ {
+		out = appendSegment(out, segments[i])
+	}
+	for _, s := range added {
+		out = appendSegment(out, s)
+	}
+	for _, s := range segments[i:] {
+		if !replaced[s.kind()] {
+			out = appendSegment(out, s)
+		}
+	}
+	return append(out, imageData...), warnings, nil
+}
+
+// segments builds the JPEG segments for m.
+func (m Metadata) segments() ([]segment, []string) {
+	var segments []segment
+	var warnings []string
+	if len(m.Exif) > 0 {
+		if len(exifHeader)+len(m.Exif) > maxSegmentData {
+			warnings = append(warnings, fmt.Sprintf("EXIF data of %d bytes does not fit into a JPEG segment", len(m.Exif)))
+		} else {
+			segments = append(segments, segment{Marker: markerAPP1, Data: concat(exifHeader, m.Exif)})
+		}
+	}
+
+	xmp, err := m.mergedXMP()
+	if err != nil {
+		warnings = append(warnings, err.Error())
+	}
+	if len(xmp) > 0 {
+		if len(xmpHeader)+len(xmp) > maxSegmentData {
+			warnings = append(warnings, fmt.Sprintf("XMP packet of %d bytes does not fit into a JPEG segment", len(xmp)))
+		} else {
+			segments = append(segments, segment{Marker: markerAPP1, Data: concat(xmpHeader, xmp)})
+		}
+	}
+
+	if len(m.ICC) > 0 {
+		count := (len(m.ICC) + maxICCChunk - 1) / maxICCChunk
+		if count > 255 {
+			warnings = append(warnings, fmt.Sprintf("ICC profile of %d bytes is too large", len(m.ICC)))
+		} else {
+			for i := 0; i < count; i++ {
+				chunk := m.ICC[i*maxICCChunk : min((i+1)*maxICCChunk, len(m.ICC))]
+				data := concat(iccHeader, []byte{byte(i + 1), byte(count)}, chunk)
+				segments = append(segments, segment{Marker: markerAPP2, Data: data})
+			}
+		}
+	}
+
+	for _, entry := range m.Text {
+		if !strings.EqualFold(entry.Keyword, "Comment") || entry.Text == "" {
+			continue
+		}
+		if len(entry.Text) > maxSegmentData {
+			warnings = append(warnings, fmt.Sprintf("comment of %d bytes does not fit into a JPEG segment", len(entry.Text)))
+			continue
+		}
+		segments = append(segments, segment{Marker: markerCOM, Data: []byte(entry.Text)})
+	}
+	return segments, warnings
+}
+
+// splitJPEG returns the segments between SOI and SOS, and everything from SOS
+// on, which is copied verbatim.
+func splitJPEG(data []byte) ([]segment, []byte, error) {
+	if len(data) < 4 || data[0] != 0xff || data[1] != markerSOI {
+		return nil, nil, fmt.Errorf("not a JPEG file")
+	}
+	var segments []segment
+	pos := 2
+	for {
+		// Markers may be preceded by fill bytes
+		for pos < len(data) && data[pos] == 0xff && pos+1 < len(data) && data[pos+1] == 0xff {
+			pos++
+		}
+		if pos+4 > len(data) || data[pos] != 0xff {
+			return nil, nil, fmt.Errorf("jpeg: invalid marker at offset %d", pos)
+		}
+		marker := data[pos+1]
+		if marker == markerSOS {
+			return segments, data[pos:], nil
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if length < 2 || pos+2+length > len(data) {
+			return nil, nil, fmt.Errorf("jpeg segment 0x%02x: %w", marker, errTruncated)
+		}
+		segments = append(segments, segment{Marker: marker, Data: data[pos+4 : pos+2+length]})
+		pos += 2 + length
+	}
+}
+
+func appendSegment(out []byte, s segment) []byte {
+	out = append(out, 0xff, s.Marker)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(s.Data)+2))
+	return append(out, s.Data...)
+}
+
+func concat(parts ...[]byte) []byte {
+	var n int
+	for _, p := range parts {
+		n += len(p)
+	}
+	out := make([]byte, 0, n)
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+// CopyAppSegments returns target with its APPn and comment segments replaced
+// by those of source, e.g. to restore the metadata of a re-encoded image.
+func CopyAppSegments(target, source []byte) ([]byte, error) {
+	sourceSegments, _, err := splitJPEG(source)
+	if err != nil {
+		return nil, err
+	}
+	targetSegments, imageData, err := splitJPEG(target)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(target)+len(source))
+	out = append(out, 0xff, markerSOI)
+	for _, s := range sourceSegments {
+		if s.isApp() {
+			out = appendSegment(out, s)
+		}
+	}
+	for _, s := range targetSegments {
+		if !s.isApp() {
+			out = appendSegment(out, s)
+		}
+	}
+	return append(out, imageData...), nil
+}
+
+// isApp reports whether s is an APPn or comment segment.
+func (s segment) isApp() bool {
+	return (s.Marker >= markerAPP0 && s.Marker <= markerAPP0+15) || s.Marker == markerCOM
+}
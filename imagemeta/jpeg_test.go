@@ -0,0 +1,168 @@
+package imagemeta
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testJPEG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	// image/jpeg writes no JFIF header, add one as cjpegli does
+	jfif := segment{Marker: markerAPP0, Data: []byte("JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00")}
+	out := appendSegment([]byte{0xff, markerSOI}, jfif)
+	return append(out, buf.Bytes()[2:]...)
+}
+
+func segmentsOf(t *testing.T, data []byte) []segment {
+	t.Helper()
+	segments, _, err := splitJPEG(data)
+	if err != nil {
+		t.Fatalf("splitJPEG() error = %v", err)
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatalf("output does not decode: %v", err)
+	}
+	return segments
+}
+
+func TestInsertJPEGFromPNGText(t *testing.T) {
+	m, err := Read(filepath.Join("testdata", "png_text.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, warnings, err := InsertJPEG(testJPEG(t), m)
+	if err != nil || len(warnings) > 0 {
+		t.Fatalf("InsertJPEG() warnings = %v, error = %v", warnings, err)
+	}
+
+	var xmp, comment string
+	for _, s := range segmentsOf(t, out) {
+		switch s.kind() {
+		case segmentXMP:
+			xmp = string(s.Data[len(xmpHeader):])
+		case segmentComment:
+			comment = string(s.Data)
+		}
+	}
+	for _, want := range []string{
+		`<rdf:li xml:lang="x-default">Sunset</rdf:li>`,
+		`<rdf:li xml:lang="de">Sonnenuntergang</rdf:li>`,
+		`<rdf:li>Jane Doe</rdf:li>`,
+		`<rdf:li xml:lang="x-default">© 2024 Jane Doe</rdf:li>`,
+		`<rdf:li xml:lang="x-default">Evening at the lake</rdf:li>`,
+		`<xmp:CreateDate>2024-05-17T10:30:00+02:00</xmp:CreateDate>`,
+	} {
+		if !strings.Contains(xmp, want) {
+			t.Errorf("XMP lacks %s:\n%s", want, xmp)
+		}
+	}
+	if comment != "Scanned from slide" {
+		t.Errorf("comment = %q", comment)
+	}
+}
+
+func TestInsertJPEGMergesExistingXMP(t *testing.T) {
+	m, err := Read(filepath.Join("testdata", "png_xmp.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _, err := InsertJPEG(testJPEG(t), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var xmp string
+	for _, s := range segmentsOf(t, out) {
+		if s.kind() == segmentXMP {
+			xmp = string(s.Data[len(xmpHeader):])
+		}
+	}
+	if !strings.Contains(xmp, "XMP title") || strings.Contains(xmp, "Ignored") {
+		t.Errorf("title of the packet must win:\n%s", xmp)
+	}
+	if !strings.Contains(xmp, "<rdf:li>Jane Doe</rdf:li>") {
+		t.Errorf("author not merged:\n%s", xmp)
+	}
+	if strings.Count(xmp, "</rdf:RDF>") != 1 {
+		t.Errorf("merged packet is malformed:\n%s", xmp)
+	}
+}
+
+func TestInsertJPEGReplacesSegments(t *testing.T) {
+	first, _, err := InsertJPEG(testJPEG(t), Metadata{Exif: []byte("old"), ICC: []byte("old profile")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Large enough to need three APP2 segments
+	icc := bytes.Repeat([]byte{0x42}, 2*maxICCChunk+10)
+	out, _, err := InsertJPEG(first, Metadata{ICC: icc})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	segments := segmentsOf(t, out)
+	if segments[0].Marker != markerAPP0 {
+		t.Errorf("first segment = 0x%02x, want JFIF APP0", segments[0].Marker)
+	}
+	var exif []string
+	var profile []byte
+	for _, s := range segments {
+		switch s.kind() {
+		case segmentExif:
+			exif = append(exif, string(s.Data[len(exifHeader):]))
+		case segmentICC:
+			header := s.Data[len(iccHeader):]
+			if int(header[0]) != len(profile)/maxICCChunk+1 || header[1] != 3 {
+				t.Errorf("ICC chunk numbering %d/%d", header[0], header[1])
+			}
+			profile = append(profile, header[2:]...)
+		}
+	}
+	if !bytes.Equal(profile, icc) {
+		t.Errorf("ICC profile has %d bytes, want %d", len(profile), len(icc))
+	}
+	if len(exif) != 1 || exif[0] != "old" {
+		t.Errorf("EXIF = %q, want the untouched old segment", exif)
+	}
+}
+
+func TestInsertJPEGRejectsOtherData(t *testing.T) {
+	if _, _, err := InsertJPEG([]byte("\x89PNG"), Metadata{}); err == nil {
+		t.Error("InsertJPEG() of non-JPEG data, want error")
+	}
+}
+
+func TestCopyAppSegments(t *testing.T) {
+	source, _, err := InsertJPEG(testJPEG(t), Metadata{XMP: []byte("<x:xmpmeta>source</x:xmpmeta>")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, _, err := InsertJPEG(testJPEG(t), Metadata{Text: []TextEntry{{Keyword: "Comment", Text: "encoder"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := CopyAppSegments(target, source)
+	if err != nil {
+		t.Fatalf("CopyAppSegments() error = %v", err)
+	}
+	var kinds []segmentKind
+	for _, s := range segmentsOf(t, out) {
+		if s.isApp() {
+			kinds = append(kinds, s.kind())
+		}
+	}
+	if len(kinds) != 2 || kinds[1] != segmentXMP {
+		t.Errorf("app segments = %v, want APP0 and the XMP of the source", kinds)
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(out)); err != nil {
+		t.Errorf("result does not decode: %v", err)
+	}
+}
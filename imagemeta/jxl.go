@@ -0,0 +1,56 @@
+package imagemeta
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// parseJXL reads the Exif and XMP boxes of a JPEG XL container. The ICC
+// profile is part of the codestream and is embedded by the encoder itself.
+func parseJXL(data []byte) (Metadata, error) {
+	var m Metadata
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return m, errTruncated
+		}
+		size := uint64(binary.BigEndian.Uint32(data[:4]))
+		boxType := string(data[4:8])
+		header := uint64(8)
+		switch size {
+		case 0:
+			// The last box extends to the end of the file
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return m, errTruncated
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			header = 16
+		}
+		if size < header || size > uint64(len(data)) {
+			return m, fmt.Errorf("jxl box %q: %w", boxType, errTruncated)
+		}
+		box := data[header:size]
+		data = data[size:]
+
+		switch boxType {
+		case "Exif":
+			// The box starts with the offset of the TIFF header within the remaining data
+			if len(box) < 4 {
+				return m, fmt.Errorf("jxl Exif box: %w", errTruncated)
+			}
+			offset := uint64(binary.BigEndian.Uint32(box[:4]))
+			if offset > uint64(len(box)-4) {
+				return m, fmt.Errorf("jxl Exif box: %w", errTruncated)
+			}
+			m.Exif = box[4+offset:]
+		case "xml ":
+			m.XMP = box
+		case "brob":
+			if len(box) >= 4 {
+				m.Warnings = append(m.Warnings, fmt.Sprintf("jxl: brotli compressed %q box is not supported", string(box[:4])))
+			}
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,423 @@
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// mpfHeader starts the APP2 segment of the CIPA Multi-Picture Format, it is
+// followed by a TIFF structure all MPF offsets are relative to.
+var mpfHeader = []byte("MPF\x00")
+
+// MPF tags and entry layout.
+const (
+	mpfTagNumberOfImages = 0xb001
+	mpfTagEntry          = 0xb002
+	mpfEntrySize         = 16
+)
+
+// mpfTypes name the MP image types of an MP entry attribute.
+var mpfTypes = map[uint32]string{
+	0x010001: "large thumbnail",
+	0x010002: "large thumbnail",
+	0x020001: "panorama frame",
+	0x020002: "disparity image",
+	0x020003: "multi-angle frame",
+	0x030000: "primary image",
+}
+
+// XMP namespaces announcing secondary images.
+var (
+	gainMapNamespace  = []byte("http://ns.adobe.com/hdr-gain-map/1.0/")
+	depthMapNamespace = []byte("http://ns.google.com/photos/1.0/depthmap/")
+	containerSemantic = regexp.MustCompile(`Item:Semantic=["']([A-Za-z]+)["']|<Item:Semantic>([A-Za-z]+)<`)
+	containerItem     = regexp.MustCompile(`<Container:Item[\s>/]`)
+	containerLength   = regexp.MustCompile(`(Item:Length=["'])[0-9]*(["'])|(<Item:Length>)[0-9]*(</Item:Length>)`)
+)
+
+// SecondaryImage is an image stored after the primary image of a JPEG.
+type SecondaryImage struct {
+	// Kind describes the image, e.g. "gain map" or "depth map"
+	Kind string
+	// Data is the complete JPEG of the image
+	Data []byte
+}
+
+// MultiPicture is the content of a JPEG beyond its primary image.
+type MultiPicture struct {
+	// MPF is the payload of the MPF APP2 segment of the primary image, nil if none
+	MPF []byte
+	// Images are the secondary images in file order
+	Images []SecondaryImage
+	// EmbeddedDepthMap is set if a depth map is stored inside the XMP
+	EmbeddedDepthMap bool
+}
+
+// Empty reports whether the JPEG holds nothing but its primary image.
+func (mp MultiPicture) Empty() bool {
+	return len(mp.Images) == 0 && !mp.EmbeddedDepthMap
+}
+
+// Kinds lists the distinct kinds of secondary images, for reports.
+func (mp MultiPicture) Kinds() []string {
+	var kinds []string
+	seen := map[string]bool{}
+	add := func(kind string) {
+		if !seen[kind] {
+			seen[kind] = true
+			kinds = append(kinds, kind)
+		}
+	}
+	for _, image := range mp.Images {
+		add(image.Kind)
+	}
+	if mp.EmbeddedDepthMap {
+		add("depth map in XMP")
+	}
+	return kinds
+}
+
+// ReadMultiPicture reads the secondary images of the JPEG at path. Other
+// formats yield an empty result.
+func ReadMultiPicture(path string) (MultiPicture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return MultiPicture{}, err
+	}
+	if !bytes.HasPrefix(data, []byte{0xff, markerSOI}) {
+		return MultiPicture{}, nil
+	}
+	return ParseMultiPicture(data)
+}
+
+// ParseMultiPicture finds the images appended to the primary image of a JPEG
+// (MPF, Ultra HDR gain maps, depth maps) and classifies them by the MPF
+// entries and the XMP container directory of the primary image.
+func ParseMultiPicture(data []byte) (MultiPicture, error) {
+	segments, _, err := splitJPEG(data)
+	if err != nil {
+		return MultiPicture{}, err
+	}
+	var mp MultiPicture
+	var xmp []byte
+	for _, s := range segments {
+		switch {
+		case s.Marker == markerAPP2 && bytes.HasPrefix(s.Data, mpfHeader) && mp.MPF == nil:
+			mp.MPF = s.Data
+		case s.kind() == segmentXMP:
+			xmp = append(xmp, s.Data...)
+		}
+	}
+	// Extended XMP, where a depth map would be stored, is also an APP1 segment
+	for _, s := range segments {
+		if s.Marker == markerAPP1 && bytes.Contains(s.Data, depthMapNamespace) && bytes.Contains(s.Data, []byte("Data")) {
+			mp.EmbeddedDepthMap = true
+		}
+	}
+
+	end, err := imageEnd(data)
+	if err != nil {
+		return MultiPicture{}, err
+	}
+	trailer := data[end:]
+	for {
+		start := bytes.Index(trailer, []byte{0xff, markerSOI, 0xff})
+		if start < 0 {
+			break
+		}
+		length, err := imageEnd(trailer[start:])
+		if err != nil {
+			break
+		}
+		mp.Images = append(mp.Images, SecondaryImage{Kind: "secondary image", Data: trailer[start : start+length]})
+		trailer = trailer[start+length:]
+	}
+
+	mp.classify(xmp)
+	return mp, nil
+}
+
+// classify names the secondary images, the XMP container directory of Ultra
+// HDR and Google depth photos is more specific than the MPF image types.
+func (mp *MultiPicture) classify(xmp []byte) {
+	if entries, _, err := mpfEntries(mp.MPF); err == nil {
+		for i, entry := range entries[1:] {
+			if i < len(mp.Images) {
+				if name, ok := mpfTypes[entry.Attribute&0xffffff]; ok {
+					mp.Images[i].Kind = name
+				}
+			}
+		}
+	}
+	var semantics []string
+	for _, match := range containerSemantic.FindAllSubmatch(xmp, -1) {
+		semantics = append(semantics, string(match[1])+string(match[2]))
+	}
+	// The first directory item is the primary image
+	if len(semantics) > 0 {
+		semantics = semantics[1:]
+	}
+	for i, semantic := range semantics {
+		if i >= len(mp.Images) {
+			break
+		}
+		switch semantic {
+		case "GainMap":
+			mp.Images[i].Kind = "gain map"
+		case "Depth":
+			mp.Images[i].Kind = "depth map"
+		case "Confidence":
+			mp.Images[i].Kind = "depth confidence map"
+		}
+	}
+	if len(semantics) == 0 && bytes.Contains(xmp, gainMapNamespace) && len(mp.Images) > 0 {
+		mp.Images[len(mp.Images)-1].Kind = "gain map"
+	}
+}
+
+// AttachMultiPicture appends the secondary images of mp to the primary JPEG
+// and inserts the MPF segment with offsets and sizes matching the new file.
+// The lengths in the XMP container directory of the primary image are updated
+// to the images, which may have been re-encoded.
+func AttachMultiPicture(primary []byte, mp MultiPicture) ([]byte, error) {
+	segments, imageData, err := splitJPEG(primary)
+	if err != nil {
+		return nil, err
+	}
+	end, err := imageEnd(primary)
+	if err != nil {
+		return nil, err
+	}
+	imageData = imageData[:len(imageData)-(len(primary)-end)]
+
+	var mpfSegment []byte
+	if mp.MPF != nil {
+		mpfSegment = append([]byte(nil), mp.MPF...)
+		entries, _, err := mpfEntries(mpfSegment)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) != len(mp.Images)+1 {
+			return nil, fmt.Errorf("mpf lists %d images, found %d", len(entries), len(mp.Images)+1)
+		}
+	}
+
+	// Rebuild the primary image with the MPF segment after the other APPn segments
+	out := []byte{0xff, markerSOI}
+	mpfPos := -1
+	inserted := mpfSegment == nil
+	for _, s := range segments {
+		if s.Marker == markerAPP2 && bytes.HasPrefix(s.Data, mpfHeader) {
+			continue
+		}
+		if s.kind() == segmentXMP {
+			s.Data = patchContainerLengths(s.Data, mp.Images)
+		}
+		if !inserted && !s.isApp() {
+			mpfPos = len(out) + 4
+			out = appendSegment(out, segment{Marker: markerAPP2, Data: mpfSegment})
+			inserted = true
+		}
+		out = appendSegment(out, s)
+	}
+	if !inserted {
+		mpfPos = len(out) + 4
+		out = appendSegment(out, segment{Marker: markerAPP2, Data: mpfSegment})
+	}
+	out = append(out, imageData...)
+
+	if mpfPos >= 0 {
+		// Offsets count from the TIFF header after "MPF\0"
+		tiffStart := mpfPos + len(mpfHeader)
+		sizes := []uint32{uint32(len(out))}
+		offsets := []uint32{0}
+		position := len(out)
+		for _, image := range mp.Images {
+			sizes = append(sizes, uint32(len(image.Data)))
+			offsets = append(offsets, uint32(position-tiffStart))
+			position += len(image.Data)
+		}
+		if err := patchMPFEntries(out[mpfPos:mpfPos+len(mpfSegment)], sizes, offsets); err != nil {
+			return nil, err
+		}
+	}
+	for _, image := range mp.Images {
+		out = append(out, image.Data...)
+	}
+	return out, nil
+}
+
+// WriteMultiPicture attaches mp to the JPEG file at path, see AttachMultiPicture.
+func WriteMultiPicture(path string, mp MultiPicture) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	updated, err := AttachMultiPicture(data, mp)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, updated, info.Mode().Perm())
+}
+
+// patchContainerLengths sets the Item:Length of the items of an XMP container
+// directory to the sizes of images. The first item is the primary image, its
+// length is left as is.
+func patchContainerLengths(xmp []byte, images []SecondaryImage) []byte {
+	items := containerItem.FindAllIndex(xmp, -1)
+	if len(items) < 2 {
+		return xmp
+	}
+	out := append([]byte(nil), xmp[:items[1][0]]...)
+	for i, item := range items[1:] {
+		end := len(xmp)
+		if i+2 < len(items) {
+			end = items[i+2][0]
+		}
+		chunk := xmp[item[0]:end]
+		if i < len(images) {
+			length := []byte(strconv.Itoa(len(images[i].Data)))
+			replaced := false
+			chunk = containerLength.ReplaceAllFunc(chunk, func(match []byte) []byte {
+				if replaced {
+					return match
+				}
+				replaced = true
+				groups := containerLength.FindSubmatch(match)
+				if groups[1] != nil {
+					return concat(groups[1], length, groups[2])
+				}
+				return concat(groups[3], length, groups[4])
+			})
+		}
+		out = append(out, chunk...)
+	}
+	return out
+}
+
+type mpfEntry struct {
+	Attribute uint32
+	Size      uint32
+	Offset    uint32
+}
+
+// mpfEntries returns the MP entries of an MPF segment and the position of the
+// first entry within the segment.
+func mpfEntries(mpf []byte) ([]mpfEntry, int, error) {
+	if !bytes.HasPrefix(mpf, mpfHeader) {
+		return nil, 0, fmt.Errorf("no mpf segment")
+	}
+	tiff := mpf[len(mpfHeader):]
+	order, ifd, err := tiffHeader(tiff)
+	if err != nil {
+		return nil, 0, err
+	}
+	if ifd+2 > len(tiff) {
+		return nil, 0, fmt.Errorf("mpf: %w", errTruncated)
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		field := ifd + 2 + i*12
+		if field+12 > len(tiff) {
+			return nil, 0, fmt.Errorf("mpf: %w", errTruncated)
+		}
+		if order.Uint16(tiff[field:]) != mpfTagEntry {
+			continue
+		}
+		size := int(order.Uint32(tiff[field+4:]))
+		offset := int(order.Uint32(tiff[field+8:]))
+		if size%mpfEntrySize != 0 || offset+size > len(tiff) {
+			return nil, 0, fmt.Errorf("mpf entries: %w", errTruncated)
+		}
+		var entries []mpfEntry
+		for pos := offset; pos < offset+size; pos += mpfEntrySize {
+			entries = append(entries, mpfEntry{
+				Attribute: order.Uint32(tiff[pos:]),
+				Size:      order.Uint32(tiff[pos+4:]),
+				Offset:    order.Uint32(tiff[pos+8:]),
+			})
+		}
+		return entries, len(mpfHeader) + offset, nil
+	}
+	return nil, 0, fmt.Errorf("mpf has no image entries")
+}
+
+func patchMPFEntries(mpf []byte, sizes, offsets []uint32) error {
+	entries, start, err := mpfEntries(mpf)
+	if err != nil {
+		return err
+	}
+	order, _, _ := tiffHeader(mpf[len(mpfHeader):])
+	for i := range entries {
+		pos := start + i*mpfEntrySize
+		order.PutUint32(mpf[pos+4:], sizes[i])
+		order.PutUint32(mpf[pos+8:], offsets[i])
+	}
+	return nil
+}
+
+func tiffHeader(tiff []byte) (binary.ByteOrder, int, error) {
+	if len(tiff) < 8 {
+		return nil, 0, fmt.Errorf("tiff header: %w", errTruncated)
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, 0, fmt.Errorf("invalid tiff byte order %q", tiff[:2])
+	}
+	return order, int(order.Uint32(tiff[4:])), nil
+}
+
+// imageEnd returns the position after the EOI marker of the JPEG image at the
+// start of data, skipping entropy coded data and all scans.
+func imageEnd(data []byte) (int, error) {
+	if len(data) < 2 || data[0] != 0xff || data[1] != markerSOI {
+		return 0, fmt.Errorf("not a JPEG image")
+	}
+	pos := 2
+	inScan := false
+	for pos+1 < len(data) {
+		if data[pos] != 0xff {
+			if !inScan {
+				return 0, fmt.Errorf("jpeg: invalid marker at offset %d", pos)
+			}
+			pos++
+			continue
+		}
+		marker := data[pos+1]
+		switch {
+		case marker == 0xff:
+			// Fill byte
+			pos++
+			continue
+		case inScan && (marker == 0x00 || (marker >= 0xd0 && marker <= 0xd7)):
+			// Stuffed zero or restart marker inside entropy coded data
+			pos += 2
+			continue
+		case marker == markerEOI:
+			return pos + 2, nil
+		}
+		if pos+4 > len(data) {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 {
+			return 0, fmt.Errorf("jpeg: invalid segment length at offset %d", pos)
+		}
+		pos += 2 + length
+		inScan = marker == markerSOS
+	}
+	return 0, fmt.Errorf("jpeg: %w", errTruncated)
+}
@@ -0,0 +1,217 @@
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+// testMPF builds an MPF segment payload with a primary and one disparity entry.
+func testMPF(primarySize, secondarySize, secondaryOffset uint32) []byte {
+	le := binary.LittleEndian
+	tiff := []byte("II\x2a\x00")
+	tiff = le.AppendUint32(tiff, 8)
+	// IFD with version, number of images and entries, no next IFD
+	tiff = le.AppendUint16(tiff, 3)
+	tiff = append(tiff, le.AppendUint16(le.AppendUint16(nil, 0xb000), 7)...)
+	tiff = append(le.AppendUint32(tiff, 4), "0100"...)
+	tiff = append(tiff, le.AppendUint16(le.AppendUint16(nil, mpfTagNumberOfImages), 4)...)
+	tiff = le.AppendUint32(le.AppendUint32(tiff, 1), 2)
+	tiff = append(tiff, le.AppendUint16(le.AppendUint16(nil, mpfTagEntry), 7)...)
+	entriesOffset := uint32(len(tiff) + 4 + 4 + 4)
+	tiff = le.AppendUint32(le.AppendUint32(tiff, 2*mpfEntrySize), entriesOffset)
+	tiff = le.AppendUint32(tiff, 0)
+	tiff = le.AppendUint32(tiff, 0x20030000)
+	tiff = le.AppendUint32(tiff, primarySize)
+	tiff = le.AppendUint32(tiff, 0)
+	tiff = le.AppendUint32(tiff, 0)
+	tiff = le.AppendUint32(tiff, 0x00020002)
+	tiff = le.AppendUint32(tiff, secondarySize)
+	tiff = le.AppendUint32(tiff, secondaryOffset)
+	tiff = le.AppendUint32(tiff, 0)
+	return append(append([]byte{}, mpfHeader...), tiff...)
+}
+
+const testContainerXMP = `<x:xmpmeta><rdf:RDF><rdf:Description xmlns:hdrgm="http://ns.adobe.com/hdr-gain-map/1.0/">
+<Container:Directory><rdf:Seq>
+<rdf:li><Container:Item Item:Semantic="Primary" Item:Mime="image/jpeg"/></rdf:li>
+<rdf:li><Container:Item Item:Semantic="GainMap" Item:Mime="image/jpeg" Item:Length="1"/></rdf:li>
+</rdf:Seq></Container:Directory></rdf:Description></rdf:RDF></x:xmpmeta>`
+
+// testMultiPicture returns a JPEG with an MPF segment and a gain map appended.
+func testMultiPicture(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	secondary := testJPEG(t)
+	primary, _, err := InsertJPEG(testJPEG(t), Metadata{XMP: []byte(testContainerXMP)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp := MultiPicture{MPF: testMPF(0, 0, 0), Images: []SecondaryImage{{Data: secondary}}}
+	data, err := AttachMultiPicture(primary, mp)
+	if err != nil {
+		t.Fatalf("AttachMultiPicture() error = %v", err)
+	}
+	return data, secondary
+}
+
+func TestParseMultiPicture(t *testing.T) {
+	data, secondary := testMultiPicture(t)
+	mp, err := ParseMultiPicture(data)
+	if err != nil {
+		t.Fatalf("ParseMultiPicture() error = %v", err)
+	}
+	if len(mp.Images) != 1 || !bytes.Equal(mp.Images[0].Data, secondary) {
+		t.Fatalf("Images = %d, want the appended image", len(mp.Images))
+	}
+	if got := mp.Kinds(); len(got) != 1 || got[0] != "gain map" {
+		t.Errorf("Kinds() = %v, want [gain map]", got)
+	}
+	if mp.MPF == nil {
+		t.Error("MPF segment not found")
+	}
+
+	plain, err := ParseMultiPicture(testJPEG(t))
+	if err != nil || !plain.Empty() {
+		t.Errorf("ParseMultiPicture(plain) = %+v, %v, want empty", plain, err)
+	}
+}
+
+func TestAttachMultiPictureCorrectsOffsets(t *testing.T) {
+	data, secondary := testMultiPicture(t)
+	mp, err := ParseMultiPicture(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A re-encoded primary image of another size
+	primary, _, err := InsertJPEG(testJPEG(t), Metadata{Text: []TextEntry{{Keyword: "Comment", Text: "re-encoded"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := AttachMultiPicture(primary, mp)
+	if err != nil {
+		t.Fatalf("AttachMultiPicture() error = %v", err)
+	}
+
+	segments := segmentsOf(t, out)
+	var mpf []byte
+	mpfPos := 0
+	pos := 2
+	for _, s := range segments {
+		if s.Marker == markerAPP2 && bytes.HasPrefix(s.Data, mpfHeader) {
+			mpf, mpfPos = s.Data, pos+4
+		}
+		pos += 4 + len(s.Data)
+	}
+	entries, _, err := mpfEntries(mpf)
+	if err != nil {
+		t.Fatalf("mpfEntries() error = %v", err)
+	}
+	primaryEnd, err := imageEnd(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(entries[0].Size) != primaryEnd || entries[0].Offset != 0 {
+		t.Errorf("primary entry = %+v, want size %d", entries[0], primaryEnd)
+	}
+	start := mpfPos + len(mpfHeader) + int(entries[1].Offset)
+	if int(entries[1].Size) != len(secondary) || !bytes.Equal(out[start:start+int(entries[1].Size)], secondary) {
+		t.Errorf("secondary entry = %+v does not point to the gain map", entries[1])
+	}
+}
+
+func TestAttachMultiPictureRejectsMismatch(t *testing.T) {
+	mp := MultiPicture{MPF: testMPF(0, 0, 0)}
+	if _, err := AttachMultiPicture(testJPEG(t), mp); err == nil {
+		t.Error("AttachMultiPicture() with missing image, want error")
+	}
+}
+
+func TestAttachMultiPictureUpdatesContainerLengths(t *testing.T) {
+	data, _ := testMultiPicture(t)
+	mp, err := ParseMultiPicture(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A re-encoded gain map of another length, behind a primary image that
+	// carries the XMP container directory of the source
+	reencoded := encodeTestJPEG(t, 50)
+	mp.Images[0].Data = reencoded
+	primary, _, err := InsertJPEG(testJPEG(t), Metadata{XMP: []byte(testContainerXMP)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := AttachMultiPicture(primary, mp)
+	if err != nil {
+		t.Fatalf("AttachMultiPicture() error = %v", err)
+	}
+
+	attached, err := ParseMultiPicture(out)
+	if err != nil {
+		t.Fatalf("ParseMultiPicture() error = %v", err)
+	}
+	if len(attached.Images) != 1 || !bytes.Equal(attached.Images[0].Data, reencoded) || attached.Images[0].Kind != "gain map" {
+		t.Fatalf("Images = %+v, want the re-encoded gain map", attached.Images)
+	}
+	entries, _, err := mpfEntries(attached.MPF)
+	if err != nil {
+		t.Fatalf("mpfEntries() error = %v", err)
+	}
+	primaryEnd, err := imageEnd(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mpfPos := bytes.Index(out, attached.MPF)
+	gainMapPos := mpfPos + len(mpfHeader) + int(entries[1].Offset)
+	if int(entries[0].Size) != primaryEnd || int(entries[1].Size) != len(reencoded) || gainMapPos != primaryEnd {
+		t.Errorf("MPF entries = %+v, want primary size %d and gain map of %d bytes at %d", entries, primaryEnd, len(reencoded), primaryEnd)
+	}
+
+	var xmp []byte
+	for _, s := range segmentsOf(t, out) {
+		if s.kind() == segmentXMP {
+			xmp = s.Data
+		}
+	}
+	lengths := containerLength.FindAllSubmatch(xmp, -1)
+	if len(lengths) != 1 {
+		t.Fatalf("XMP has %d Item:Length, want 1", len(lengths))
+	}
+	want := fmt.Sprintf(`Item:Length="%d"`, len(reencoded))
+	if !bytes.Contains(xmp, []byte(want)) {
+		t.Errorf("XMP container directory = %s, want %s", xmp, want)
+	}
+}
+
+func TestPatchContainerLengths(t *testing.T) {
+	images := []SecondaryImage{{Data: make([]byte, 1234)}, {Data: make([]byte, 56)}}
+	tests := []struct {
+		name string
+		xmp  string
+		want string
+	}{
+		{
+			name: "attributes",
+			xmp:  `<Container:Item Item:Semantic="Primary" Item:Length="0"/><Container:Item Item:Semantic="Depth" Item:Length="9"/><Container:Item Item:Semantic="Confidence" Item:Length='8'/>`,
+			want: `<Container:Item Item:Semantic="Primary" Item:Length="0"/><Container:Item Item:Semantic="Depth" Item:Length="1234"/><Container:Item Item:Semantic="Confidence" Item:Length='56'/>`,
+		},
+		{
+			name: "elements",
+			xmp:  `<Container:Item><Item:Semantic>Primary</Item:Semantic></Container:Item><Container:Item><Item:Semantic>GainMap</Item:Semantic><Item:Length>1</Item:Length></Container:Item>`,
+			want: `<Container:Item><Item:Semantic>Primary</Item:Semantic></Container:Item><Container:Item><Item:Semantic>GainMap</Item:Semantic><Item:Length>1234</Item:Length></Container:Item>`,
+		},
+		{
+			name: "no directory",
+			xmp:  `<rdf:Description hdrgm:Version="1.0"/>`,
+			want: `<rdf:Description hdrgm:Version="1.0"/>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(patchContainerLengths([]byte(tt.xmp), images)); got != tt.want {
+				t.Errorf("patchContainerLengths() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package imagemeta
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxInflatedSize limits decompressed chunks, so a malicious file cannot
+// exhaust memory.
+const maxInflatedSize = 64 << 20
+
+// xmpKeyword is the iTXt keyword of an XMP packet.
+const xmpKeyword = "XML:com.adobe.xmp"
+
+// rawProfilePrefix starts the keyword of profiles written by ImageMagick as
+// hex encoded text chunks, e.g. "Raw profile type exif".
+const rawProfilePrefix = "raw profile type "
+
+// exifHeader precedes the TIFF structure in a JPEG APP1 segment, some writers
+// copy it into PNG eXIf chunks and raw profiles as well.
+var exifHeader = []byte("Exif\x00\x00")
+
+func parsePNG(data []byte) (Metadata, error) {
+	var m Metadata
+	for len(data) > 0 {
+		if len(data) < 12 {
+			return m, errTruncated
+		}
+		length := binary.BigEndian.Uint32(data[:4])
+		chunkType := string(data[4:8])
+		if uint64(length) > uint64(len(data)-12) {
+			return m, fmt.Errorf("png chunk %s: %w", chunkType, errTruncated)
+		}
+		chunk := data[8 : 8+length]
+		data = data[12+length:]
+
+		if err := m.addPNGChunk(chunkType, chunk); err != nil {
+			m.Warnings = append(m.Warnings, fmt.Sprintf("png chunk %s: %s", chunkType, err))
+		}
+		if chunkType == "IEND" {
+			break
+		}
+	}
+	return m, nil
+}
+
+func (m *Metadata) addPNGChunk(chunkType string, chunk []byte) error {
+	switch chunkType {
+	case "eXIf":
+		m.Exif = bytes.TrimPrefix(chunk, exifHeader)
+	case "iCCP":
+		_, rest, ok := bytes.Cut(chunk, []byte{0})
+		if !ok || len(rest) < 1 {
+			return errTruncated
+		}
+		profile, err := inflate(rest[0], rest[1:])
+		if err != nil {
+			return err
+		}
+		m.ICC = profile
+	case "sRGB":
+		m.SRGB = true
+	case "acTL":
+		if len(chunk) != 8 {
+			return errTruncated
+		}
+		if frames := binary.BigEndian.Uint32(chunk); frames > 1 {
+			m.Frames = int(min(frames, math.MaxInt32))
+		}
+	case "gAMA":
+		if len(chunk) != 4 {
+			return errTruncated
+		}
+		// The chunk stores the encoding gamma times 100000, e.g. 45455 for 1/2.2
+		if g := binary.BigEndian.Uint32(chunk); g > 0 {
+			m.Gamma = 100000 / float64(g)
+		}
+	case "tEXt":
+		keyword, text, ok := bytes.Cut(chunk, []byte{0})
+		if !ok {
+			return errTruncated
+		}
+		return m.addText(TextEntry{Keyword: string(keyword), Text: latin1ToUTF8(text)})
+	case "zTXt":
+		keyword, rest, ok := bytes.Cut(chunk, []byte{0})
+		if !ok || len(rest) < 1 {
+			return errTruncated
+		}
+		text, err := inflate(rest[0], rest[1:])
+		if err != nil {
+			return err
+		}
+		return m.addText(TextEntry{Keyword: string(keyword), Text: latin1ToUTF8(text)})
+	case "iTXt":
+		return m.addInternationalText(chunk)
+	}
+	return nil
+}
+
+// addInternationalText parses an iTXt chunk: keyword, compression flag and
+// method, language tag, translated keyword and UTF-8 text.
+func (m *Metadata) addInternationalText(chunk []byte) error {
+	keyword, rest, ok := bytes.Cut(chunk, []byte{0})
+	if !ok || len(rest) < 2 {
+		return errTruncated
+	}
+	compressed, method := rest[0] == 1, rest[1]
+	language, rest, ok := bytes.Cut(rest[2:], []byte{0})
+	if !ok {
+		return errTruncated
+	}
+	_, text, ok := bytes.Cut(rest, []byte{0})
+	if !ok {
+		return errTruncated
+	}
+	if compressed {
+		var err error
+		if text, err = inflate(method, text); err != nil {
+			return err
+		}
+	}
+	if !utf8.Valid(text) {
+		return fmt.Errorf("text of %s is not valid UTF-8", keyword)
+	}
+	return m.addText(TextEntry{Keyword: string(keyword), Language: string(language), Text: string(text)})
+}
+
+// addText stores a text entry, or the profile it carries.
+func (m *Metadata) addText(entry TextEntry) error {
+	if entry.Keyword == xmpKeyword {
+		m.XMP = []byte(entry.Text)
+		return nil
+	}
+	profileType, ok := cutPrefixFold(entry.Keyword, rawProfilePrefix)
+	if !ok {
+		m.Text = append(m.Text, entry)
+		return nil
+	}
+	profile, err := decodeRawProfile(entry.Text)
+	if err != nil {
+		return fmt.Errorf("%s: %w", entry.Keyword, err)
+	}
+	switch strings.ToLower(profileType) {
+	case "exif", "app1":
+		if bytes.HasPrefix(profile, xmpHeader) {
+			m.XMP = profile[len(xmpHeader):]
+		} else {
+			m.Exif = bytes.TrimPrefix(profile, exifHeader)
+		}
+	case "xmp":
+		m.XMP = profile
+	case "icc", "icm":
+		m.ICC = profile
+	default:
+		return fmt.Errorf("unsupported raw profile %q", profileType)
+	}
+	return nil
+}
+
+// decodeRawProfile decodes the ImageMagick raw profile format: a line with the
+// profile name, a line with the decimal length and the hex encoded data.
+func decodeRawProfile(text string) ([]byte, error) {
+	fields := strings.Fields(text)
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("raw profile: %w", errTruncated)
+	}
+	length, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("raw profile length: %w", err)
+	}
+	data, err := hex.DecodeString(strings.Join(fields[2:], ""))
+	if err != nil {
+		return nil, fmt.Errorf("raw profile data: %w", err)
+	}
+	if len(data) != length {
+		return nil, fmt.Errorf("raw profile has %d bytes, expected %d", len(data), length)
+	}
+	return data, nil
+}
+
+func inflate(method byte, data []byte) ([]byte, error) {
+	if method != 0 {
+		return nil, fmt.Errorf("unknown compression method %d", method)
+	}
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	inflated, err := io.ReadAll(io.LimitReader(r, maxInflatedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(inflated) > maxInflatedSize {
+		return nil, fmt.Errorf("decompressed data exceeds %d bytes", maxInflatedSize)
+	}
+	return inflated, nil
+}
+
+// latin1ToUTF8 converts ISO 8859-1 text, used by tEXt and zTXt chunks.
+func latin1ToUTF8(text []byte) string {
+	runes := make([]rune, len(text))
+	for i, b := range text {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
@@ -0,0 +1,224 @@
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+const markerDQT = 0xdb
+
+// Encoders recognized by their quantization tables or segments.
+const (
+	EncoderLibjpeg   = "libjpeg"
+	EncoderMozjpeg   = "mozjpeg"
+	EncoderJpegli    = "jpegli"
+	EncoderPhotoshop = "Photoshop"
+	EncoderCamera    = "camera"
+)
+
+// QualityEstimate is the libjpeg-equivalent quality of a JPEG, estimated from
+// its quantization tables.
+type QualityEstimate struct {
+	// Quality is 1 to 100 as passed to libjpeg, 0 if unknown
+	Quality int
+	// Exact is set if the tables are exactly those of Encoder at Quality,
+	// otherwise Quality is the closest fit to the libjpeg tables
+	Exact bool
+	// Encoder is the likely encoder, empty if not recognized
+	Encoder string
+	// Tables is the number of quantization tables
+	Tables int
+}
+
+// String describes the estimate for reports, e.g. "quality 85 (libjpeg)" or
+// "quality ~92, likely camera".
+func (q QualityEstimate) String() string {
+	switch {
+	case q.Quality == 0:
+		return "quality unknown"
+	case q.Exact:
+		return fmt.Sprintf("quality %d (%s)", q.Quality, q.Encoder)
+	case q.Encoder != "":
+		return fmt.Sprintf("quality ~%d, likely %s", q.Quality, q.Encoder)
+	}
+	return fmt.Sprintf("quality ~%d", q.Quality)
+}
+
+// naturalOrder maps the zigzag position of a DQT entry to its position in the
+// 8x8 block.
+var naturalOrder = [64]int{
+	0, 1, 8, 16, 9, 2, 3, 10, 17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34, 27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36, 29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46, 53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+// Base tables in natural order, scaled by the quality. libjpeg uses the tables
+// of the JPEG standard (Annex K), mozjpeg defaults to the table tuned by N.
+// Robidoux for both luminance and chrominance.
+var (
+	libjpegLuminance = [64]uint16{
+		16, 11, 10, 16, 24, 40, 51, 61,
+		12, 12, 14, 19, 26, 58, 60, 55,
+		14, 13, 16, 24, 40, 57, 69, 56,
+		14, 17, 22, 29, 51, 87, 80, 62,
+		18, 22, 37, 56, 68, 109, 103, 77,
+		24, 35, 55, 64, 81, 104, 113, 92,
+		49, 64, 78, 87, 103, 121, 120, 101,
+		72, 92, 95, 98, 112, 100, 103, 99,
+	}
+	libjpegChrominance = [64]uint16{
+		17, 18, 24, 47, 99, 99, 99, 99,
+		18, 21, 26, 66, 99, 99, 99, 99,
+		24, 26, 56, 99, 99, 99, 99, 99,
+		47, 66, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+	}
+	mozjpegTable = [64]uint16{
+		16, 16, 16, 18, 25, 37, 56, 85,
+		16, 17, 20, 27, 34, 40, 53, 75,
+		16, 20, 24, 31, 43, 62, 91, 135,
+		18, 27, 31, 40, 53, 74, 106, 156,
+		25, 34, 43, 53, 69, 94, 131, 189,
+		37, 40, 62, 74, 94, 124, 169, 238,
+		56, 53, 91, 106, 131, 169, 226, 311,
+		85, 75, 135, 156, 189, 238, 311, 418,
+	}
+)
+
+// ReadQuality estimates the quality of the JPEG at path.
+func ReadQuality(path string) (QualityEstimate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return QualityEstimate{}, err
+	}
+	return EstimateQuality(data)
+}
+
+// EstimateQuality estimates the quality of a JPEG from the tables of its DQT
+// segments and recognizes the encoder where the tables or segments give it
+// away. Table 0 is taken as luminance and table 1 as chrominance table.
+func EstimateQuality(data []byte) (QualityEstimate, error) {
+	segments, _, err := splitJPEG(data)
+	if err != nil {
+		return QualityEstimate{}, err
+	}
+	tables := map[int][64]uint16{}
+	adobe, exif := false, false
+	for _, s := range segments {
+		switch {
+		case s.Marker == markerDQT:
+			if err := parseDQT(s.Data, tables); err != nil {
+				return QualityEstimate{}, err
+			}
+		case s.Marker == markerAPP0+14 && bytes.HasPrefix(s.Data, adobeHeader):
+			adobe = true
+		case s.kind() == segmentExif:
+			exif = true
+		}
+	}
+	luminance, ok := tables[0]
+	if !ok {
+		return QualityEstimate{}, fmt.Errorf("jpeg has no luminance quantization table")
+	}
+	chrominance, hasChrominance := tables[1]
+
+	estimate := QualityEstimate{Tables: len(tables)}
+	for _, family := range []struct {
+		encoder                string
+		luminance, chrominance *[64]uint16
+	}{
+		{EncoderLibjpeg, &libjpegLuminance, &libjpegChrominance},
+		{EncoderMozjpeg, &mozjpegTable, &mozjpegTable},
+	} {
+		best, bestError := 0, -1
+		for quality := 1; quality <= 100; quality++ {
+			e := tableError(luminance, family.luminance, quality)
+			if hasChrominance {
+				e += tableError(chrominance, family.chrominance, quality)
+			}
+			// Ties go to the higher quality, the tables of the highest
+			// qualities only differ in a few entries
+			if bestError < 0 || e <= bestError {
+				best, bestError = quality, e
+			}
+		}
+		if bestError == 0 {
+			estimate.Quality, estimate.Exact, estimate.Encoder = best, true, family.encoder
+			return estimate, nil
+		}
+		// The closest libjpeg quality is the estimate for all other encoders
+		if estimate.Quality == 0 {
+			estimate.Quality = best
+		}
+	}
+
+	// jpegli derives a table per component from the distance, others share
+	// the chrominance table between Cb and Cr
+	switch {
+	case len(tables) >= 3:
+		estimate.Encoder = EncoderJpegli
+	case adobe:
+		estimate.Encoder = EncoderPhotoshop
+	case exif:
+		estimate.Encoder = EncoderCamera
+	}
+	return estimate, nil
+}
+
+// parseDQT adds the tables of a DQT segment to tables, in natural order.
+func parseDQT(data []byte, tables map[int][64]uint16) error {
+	for len(data) > 0 {
+		precision, id := data[0]>>4, int(data[0]&0x0f)
+		size := 64
+		if precision != 0 {
+			size = 128
+		}
+		if len(data) < 1+size {
+			return fmt.Errorf("jpeg quantization table: %w", errTruncated)
+		}
+		var table [64]uint16
+		for i := range 64 {
+			if precision != 0 {
+				table[naturalOrder[i]] = binary.BigEndian.Uint16(data[1+2*i:])
+			} else {
+				table[naturalOrder[i]] = uint16(data[1+i])
+			}
+		}
+		tables[id] = table
+		data = data[1+size:]
+	}
+	return nil
+}
+
+// tableError sums the differences between table and base scaled to quality.
+func tableError(table [64]uint16, base *[64]uint16, quality int) int {
+	sum := 0
+	for i, value := range scaleTable(base, quality) {
+		d := int(table[i]) - int(value)
+		if d < 0 {
+			d = -d
+		}
+		sum += d
+	}
+	return sum
+}
+
+// scaleTable scales base to quality as jpeg_set_quality does for baseline
+// files.
+func scaleTable(base *[64]uint16, quality int) [64]uint16 {
+	scale := 200 - 2*quality
+	if quality < 50 {
+		scale = 5000 / quality
+	}
+	var table [64]uint16
+	for i, value := range base {
+		table[i] = uint16(min(max((int(value)*scale+50)/100, 1), 255))
+	}
+	return table
+}
@@ -0,0 +1,147 @@
+package imagemeta
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+)
+
+func encodeTestJPEG(t *testing.T, quality int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testDQTHeader returns a JPEG header with a DQT segment per table, given in
+// natural order, and extra segments before them.
+func testDQTHeader(tables [][64]uint16, extra ...segment) []byte {
+	out := []byte{0xff, markerSOI}
+	for _, s := range extra {
+		out = appendSegment(out, s)
+	}
+	for id, table := range tables {
+		data := []byte{byte(id)}
+		for i := range 64 {
+			data = append(data, byte(table[naturalOrder[i]]))
+		}
+		out = appendSegment(out, segment{Marker: markerDQT, Data: data})
+	}
+	return append(out, 0xff, markerSOS, 0, 2)
+}
+
+// distorted returns table with one entry changed, so it matches no encoder.
+func distorted(table [64]uint16) [64]uint16 {
+	table[63]++
+	return table
+}
+
+func TestEstimateQuality(t *testing.T) {
+	for _, quality := range []int{10, 50, 75, 90, 100} {
+		estimate, err := EstimateQuality(encodeTestJPEG(t, quality))
+		if err != nil {
+			t.Fatalf("EstimateQuality(q%d) error = %v", quality, err)
+		}
+		want := QualityEstimate{Quality: quality, Exact: true, Encoder: EncoderLibjpeg, Tables: 2}
+		if estimate != want {
+			t.Errorf("EstimateQuality(q%d) = %+v, want %+v", quality, estimate, want)
+		}
+	}
+}
+
+func TestEstimateQualityEncoders(t *testing.T) {
+	luminance := scaleTable(&libjpegLuminance, 85)
+	chrominance := scaleTable(&libjpegChrominance, 85)
+	exif := segment{Marker: markerAPP1, Data: append(bytes.Clone(exifHeader), "II*\x00"...)}
+	adobe := segment{Marker: markerAPP0 + 14, Data: []byte{'A', 'd', 'o', 'b', 'e', 0, 100, 0, 0, 0, 0, 1}}
+
+	tests := []struct {
+		name   string
+		data   []byte
+		want   QualityEstimate
+		report string
+	}{
+		{
+			name:   "mozjpeg",
+			data:   testDQTHeader([][64]uint16{scaleTable(&mozjpegTable, 80), scaleTable(&mozjpegTable, 80)}),
+			want:   QualityEstimate{Quality: 80, Exact: true, Encoder: EncoderMozjpeg, Tables: 2},
+			report: "quality 80 (mozjpeg)",
+		},
+		{
+			name:   "jpegli",
+			data:   testDQTHeader([][64]uint16{distorted(luminance), chrominance, distorted(chrominance)}),
+			want:   QualityEstimate{Quality: 85, Encoder: EncoderJpegli, Tables: 3},
+			report: "quality ~85, likely jpegli",
+		},
+		{
+			name:   "photoshop",
+			data:   testDQTHeader([][64]uint16{distorted(luminance), chrominance}, exif, adobe),
+			want:   QualityEstimate{Quality: 85, Encoder: EncoderPhotoshop, Tables: 2},
+			report: "quality ~85, likely Photoshop",
+		},
+		{
+			name:   "camera",
+			data:   testDQTHeader([][64]uint16{distorted(luminance), chrominance}, exif),
+			want:   QualityEstimate{Quality: 85, Encoder: EncoderCamera, Tables: 2},
+			report: "quality ~85, likely camera",
+		},
+		{
+			name:   "unknown",
+			data:   testDQTHeader([][64]uint16{distorted(luminance)}),
+			want:   QualityEstimate{Quality: 85, Tables: 1},
+			report: "quality ~85",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate, err := EstimateQuality(tt.data)
+			if err != nil {
+				t.Fatalf("EstimateQuality() error = %v", err)
+			}
+			if estimate != tt.want {
+				t.Errorf("EstimateQuality() = %+v, want %+v", estimate, tt.want)
+			}
+			if got := estimate.String(); got != tt.report {
+				t.Errorf("String() = %q, want %q", got, tt.report)
+			}
+		})
+	}
+}
+
+func TestEstimateQualityErrors(t *testing.T) {
+	if _, err := EstimateQuality(testDQTHeader(nil)); err == nil {
+		t.Error("EstimateQuality() without tables, want error")
+	}
+	truncated := testDQTHeader(nil, segment{Marker: markerDQT, Data: []byte{0x10, 1, 2}})
+	if _, err := EstimateQuality(truncated); err == nil {
+		t.Error("EstimateQuality() with truncated table, want error")
+	}
+	if _, err := EstimateQuality([]byte("\x89PNG\r\n\x1a\n")); err == nil {
+		t.Error("EstimateQuality() of a PNG, want error")
+	}
+}
+
+func TestParseDQTSixteenBit(t *testing.T) {
+	data := []byte{0x10}
+	for i := range 64 {
+		data = append(data, 0x01, byte(i))
+	}
+	tables := map[int][64]uint16{}
+	if err := parseDQT(data, tables); err != nil {
+		t.Fatal(err)
+	}
+	// The last zigzag entry is the bottom right coefficient
+	if got := tables[0][63]; got != 0x013f {
+		t.Errorf("table[63] = %#x, want 0x13f", got)
+	}
+	if got := tables[0][8]; got != 0x0102 {
+		t.Errorf("table[8] = %#x, want 0x102", got)
+	}
+}
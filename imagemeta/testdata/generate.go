@@ -0,0 +1,193 @@
+//go:build ignore
+
+// generate writes the metadata test corpus of the imagemeta package:
+//
+//	go run generate.go
+//
+// Every PNG and GIF is a valid 2x2 image with the metadata chunks inserted
+// after the header. The JPEG XL files use a placeholder codestream, only their
+// container boxes are read by the tests.
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"log"
+	"os"
+	"strings"
+)
+
+// Fixture values shared with the tests.
+const (
+	exifTIFF   = "MM\x00\x2a\x00\x00\x00\x08\x00\x00\x00\x00\x00\x00"
+	iccProfile = "fake ICC profile for tests"
+	xmpPacket  = `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?><x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title><rdf:Alt><rdf:li xml:lang="x-default">XMP title</rdf:li></rdf:Alt></dc:title></rdf:Description></rdf:RDF></x:xmpmeta><?xpacket end="w"?>`
+)
+
+func main() {
+	write("png_text.png", pngWith(
+		chunk("tEXt", text("Title", "Sunset")),
+		chunk("tEXt", text("Author", "Jane Doe")),
+		chunk("tEXt", text("Copyright", "\xa9 2024 Jane Doe")),
+		chunk("tEXt", text("Creation Time", "Fri, 17 May 2024 10:30:00 +0200")),
+		chunk("zTXt", compressedText("Description", "Evening at the lake")),
+		chunk("iTXt", internationalText("Title", "de", "Sonnenuntergang", true)),
+		chunk("tEXt", text("Comment", "Scanned from slide")),
+	))
+	write("png_exif_icc_gamma.png", pngWith(
+		chunk("eXIf", []byte(exifTIFF)),
+		chunk("iCCP", append([]byte("test profile\x00\x00"), deflate([]byte(iccProfile))...)),
+		chunk("gAMA", u32(45455)),
+	))
+	write("png_srgb.png", pngWith(
+		chunk("sRGB", []byte{0}),
+		chunk("gAMA", u32(45455)),
+	))
+	write("png_xmp.png", pngWith(
+		chunk("iTXt", internationalText("XML:com.adobe.xmp", "", xmpPacket, false)),
+		chunk("tEXt", text("Title", "Ignored, the packet has a title")),
+		chunk("tEXt", text("Author", "Jane Doe")),
+	))
+	write("png_raw_profiles.png", pngWith(
+		chunk("zTXt", compressedText("Raw profile type exif", rawProfile("exif", []byte("Exif\x00\x00"+exifTIFF)))),
+		chunk("zTXt", compressedText("Raw profile type icc", rawProfile("icc", []byte(iccProfile)))),
+	))
+	write("jxl_boxes.jxl", jxlWith(
+		box("Exif", append(u32(0), exifTIFF...)),
+		box("xml ", []byte(xmpPacket)),
+		box("brob", []byte("Exif\x1b\x00\x00")),
+	))
+	write("gif_comment_xmp.gif", gifWith("Made with GIMP", xmpPacket))
+}
+
+func write(name string, data []byte) {
+	if err := os.WriteFile(name, data, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func testImage() *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, 2, 2), color.Palette{color.Black, color.White})
+	img.SetColorIndex(1, 1, 1)
+	return img
+}
+
+// pngWith encodes the test image and inserts chunks after IHDR.
+func pngWith(chunks ...[]byte) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage()); err != nil {
+		log.Fatal(err)
+	}
+	data := buf.Bytes()
+	// Signature (8) and IHDR (4 length + 4 type + 13 data + 4 crc)
+	headerEnd := 8 + 25
+	out := append([]byte{}, data[:headerEnd]...)
+	for _, c := range chunks {
+		out = append(out, c...)
+	}
+	return append(out, data[headerEnd:]...)
+}
+
+func chunk(chunkType string, data []byte) []byte {
+	out := u32(uint32(len(data)))
+	out = append(out, chunkType...)
+	out = append(out, data...)
+	return append(out, u32(crc32.ChecksumIEEE(append([]byte(chunkType), data...)))...)
+}
+
+func text(keyword, value string) []byte {
+	return []byte(keyword + "\x00" + value)
+}
+
+func compressedText(keyword, value string) []byte {
+	return append([]byte(keyword+"\x00\x00"), deflate([]byte(value))...)
+}
+
+func internationalText(keyword, language, value string, compressed bool) []byte {
+	out := []byte(keyword + "\x00")
+	if compressed {
+		out = append(out, 1, 0)
+	} else {
+		out = append(out, 0, 0)
+	}
+	out = append(out, language+"\x00\x00"...)
+	if compressed {
+		return append(out, deflate([]byte(value))...)
+	}
+	return append(out, value...)
+}
+
+// rawProfile encodes data in the ImageMagick raw profile format.
+func rawProfile(name string, data []byte) string {
+	encoded := hex.EncodeToString(data)
+	var lines []string
+	for len(encoded) > 72 {
+		lines = append(lines, encoded[:72])
+		encoded = encoded[72:]
+	}
+	lines = append(lines, encoded)
+	return fmt.Sprintf("\n%s\n%8d\n%s\n", name, len(data), strings.Join(lines, "\n"))
+}
+
+func deflate(data []byte) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func u32(v uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+func jxlWith(boxes ...[]byte) []byte {
+	out := []byte("\x00\x00\x00\x0cJXL \r\n\x87\n")
+	out = append(out, box("ftyp", []byte("jxl \x00\x00\x00\x00jxl "))...)
+	for _, b := range boxes {
+		out = append(out, b...)
+	}
+	// Placeholder codestream, starting with the JPEG XL codestream marker
+	return append(out, box("jxlc", []byte{0xff, 0x0a, 0x00, 0x00})...)
+}
+
+func box(boxType string, data []byte) []byte {
+	out := u32(uint32(8 + len(data)))
+	out = append(out, boxType...)
+	return append(out, data...)
+}
+
+// gifWith encodes the test image and inserts a comment and an XMP extension
+// before the image descriptor.
+func gifWith(comment, xmp string) []byte {
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, testImage(), nil); err != nil {
+		log.Fatal(err)
+	}
+	data := buf.Bytes()
+	imageStart := bytes.IndexByte(data[13+6:], 0x2c) + 13 + 6
+
+	ext := []byte{0x21, 0xfe, byte(len(comment))}
+	ext = append(ext, comment...)
+	ext = append(ext, 0)
+	ext = append(ext, 0x21, 0xff, 11)
+	ext = append(ext, "XMP DataXMP"...)
+	ext = append(ext, xmp...)
+	ext = append(ext, 0x01)
+	for i := 0xff; i >= 0; i-- {
+		ext = append(ext, byte(i))
+	}
+	ext = append(ext, 0)
+
+	out := append([]byte{}, data[:imageStart]...)
+	out = append(out, ext...)
+	return append(out, data[imageStart:]...)
+}
@@ -0,0 +1,155 @@
+package imagemeta
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// markerSOF lists the start of frame markers, they hold the component count.
+var markerSOF = []byte{0xc0, 0xc1, 0xc2, 0xc3, 0xc5, 0xc6, 0xc7, 0xc9, 0xca, 0xcb, 0xcd, 0xce, 0xcf}
+
+// adobeHeader starts the APP14 segment of Adobe, its last byte is the color
+// transform the decoder applies.
+var adobeHeader = []byte("Adobe")
+
+// adobeTransformOffset is the position of the color transform in the APP14 payload.
+const adobeTransformOffset = 11
+
+// ParseSegmentName returns the marker of a segment name, "APP0" to "APP15"
+// or "COM". APP1 and APP2 hold EXIF, XMP, ICC and MPF, which exiftool and the
+// converter write, so they cannot be transplanted.
+func ParseSegmentName(name string) (byte, error) {
+	upper := strings.ToUpper(strings.TrimSpace(name))
+	if upper == "COM" {
+		return markerCOM, nil
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(upper, "APP"))
+	if !strings.HasPrefix(upper, "APP") || err != nil || n < 0 || n > 15 {
+		return 0, fmt.Errorf("unknown segment %q, expected APP0 to APP15 or COM", name)
+	}
+	if n == 1 || n == 2 {
+		return 0, fmt.Errorf("segment %s holds EXIF, XMP or ICC data and cannot be transplanted", upper)
+	}
+	return markerAPP0 + byte(n), nil
+}
+
+// SegmentName is the inverse of ParseSegmentName.
+func SegmentName(marker byte) string {
+	if marker == markerCOM {
+		return "COM"
+	}
+	return fmt.Sprintf("APP%d", marker-markerAPP0)
+}
+
+// TransplantSegments returns target with all segments of the given markers
+// replaced by those of source, copied byte for byte and in source order. The
+// image data of target is unchanged. It returns the markers of the copied
+// segments.
+//
+// The color transform of an Adobe APP14 segment is set to match the encoding
+// of target, otherwise decoders would convert the colors wrongly.
+func TransplantSegments(target, source []byte, markers []byte) ([]byte, []byte, error) {
+	sourceSegments, _, err := splitJPEG(source)
+	if err != nil {
+		return nil, nil, fmt.Errorf("source: %w", err)
+	}
+	targetSegments, imageData, err := splitJPEG(target)
+	if err != nil {
+		return nil, nil, fmt.Errorf("target: %w", err)
+	}
+	selected := func(s segment) bool {
+		return bytes.IndexByte(markers, s.Marker) >= 0
+	}
+
+	var transplanted []segment
+	var copied []byte
+	for _, s := range sourceSegments {
+		if selected(s) {
+			if s.Marker == markerAPP0+14 && bytes.HasPrefix(s.Data, adobeHeader) && len(s.Data) > adobeTransformOffset {
+				s.Data = bytes.Clone(s.Data)
+				s.Data[adobeTransformOffset] = adobeTransform(targetSegments)
+			}
+			transplanted = append(transplanted, s)
+			copied = append(copied, s.Marker)
+		}
+	}
+
+	var kept, tables []segment
+	for _, s := range targetSegments {
+		switch {
+		case selected(s):
+		case s.isApp():
+			kept = append(kept, s)
+		default:
+			tables = append(tables, s)
+		}
+	}
+
+	// JFIF requires APP0 first and EXIF directly after it, transplanted
+	// segments follow the metadata of target
+	out := make([]byte, 0, len(target)+len(source))
+	out = append(out, 0xff, markerSOI)
+	for _, group := range [][]segment{kept, transplanted} {
+		for _, s := range group {
+			if s.Marker == markerAPP0 {
+				out = appendSegment(out, s)
+			}
+		}
+	}
+	for _, group := range [][]segment{kept, transplanted, tables} {
+		for _, s := range group {
+			if s.Marker != markerAPP0 {
+				out = appendSegment(out, s)
+			}
+		}
+	}
+	return append(out, imageData...), copied, nil
+}
+
+// WriteTransplant copies the segments of the given markers from the JPEG at
+// sourcePath into the JPEG at targetPath, see TransplantSegments. Sources
+// other than JPEG have no segments to copy.
+func WriteTransplant(targetPath, sourcePath string, markers []byte) ([]byte, error) {
+	source, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(source, []byte{0xff, markerSOI}) {
+		return nil, nil
+	}
+	target, err := os.ReadFile(targetPath)
+	if err != nil {
+		return nil, err
+	}
+	updated, copied, err := TransplantSegments(target, source, markers)
+	if err != nil || len(copied) == 0 {
+		return nil, err
+	}
+	info, err := os.Stat(targetPath)
+	if err != nil {
+		return nil, err
+	}
+	return copied, os.WriteFile(targetPath, updated, info.Mode().Perm())
+}
+
+// adobeTransform returns the APP14 color transform matching segments: that of
+// their own APP14 segment, else YCbCr (1) for three components, none (0) for
+// grayscale.
+func adobeTransform(segments []segment) byte {
+	components := 0
+	for _, s := range segments {
+		if s.Marker == markerAPP0+14 && bytes.HasPrefix(s.Data, adobeHeader) && len(s.Data) > adobeTransformOffset {
+			return s.Data[adobeTransformOffset]
+		}
+		if bytes.IndexByte(markerSOF, s.Marker) >= 0 && len(s.Data) >= 6 {
+			components = int(s.Data[5])
+		}
+	}
+	if components == 3 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,98 @@
+package imagemeta
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseSegmentName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    byte
+		wantErr bool
+	}{
+		{name: "APP13", want: 0xed},
+		{name: " app14", want: 0xee},
+		{name: "APP0", want: markerAPP0},
+		{name: "COM", want: markerCOM},
+		{name: "APP1", wantErr: true},
+		{name: "APP2", wantErr: true},
+		{name: "APP16", wantErr: true},
+		{name: "DQT", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSegmentName(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseSegmentName(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseSegmentName(%q) = 0x%02x, want 0x%02x", tt.name, got, tt.want)
+		}
+		if !tt.wantErr && SegmentName(got) != strings.ToUpper(strings.TrimSpace(tt.name)) {
+			t.Errorf("SegmentName(0x%02x) = %q", got, SegmentName(got))
+		}
+	}
+}
+
+func TestTransplantSegments(t *testing.T) {
+	photoshop := segment{Marker: 0xed, Data: []byte("Photoshop 3.0\x008BIM\x07\xd0clipping path")}
+	// Adobe APP14 of a CMYK file, transform 2 (YCCK)
+	adobe := segment{Marker: 0xee, Data: []byte("Adobe\x00\x64\x00\x00\x00\x00\x02")}
+	vendor := segment{Marker: 0xe5, Data: []byte("vendor")}
+	source := testJPEG(t)
+	segments, imageData, err := splitJPEG(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source = []byte{0xff, markerSOI}
+	for _, s := range append(segments, photoshop, vendor, adobe) {
+		source = appendSegment(source, s)
+	}
+	source = append(source, imageData...)
+
+	// exiftool rewrote APP13 in the target
+	target, _, err := InsertJPEG(testJPEG(t), Metadata{Exif: []byte("exif")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	segments, imageData, _ = splitJPEG(target)
+	target = []byte{0xff, markerSOI}
+	for _, s := range append(segments, segment{Marker: 0xed, Data: []byte("Photoshop 3.0\x00rewritten")}) {
+		target = appendSegment(target, s)
+	}
+	target = append(target, imageData...)
+
+	out, copied, err := TransplantSegments(target, source, []byte{0xed, 0xee})
+	if err != nil {
+		t.Fatalf("TransplantSegments() error = %v", err)
+	}
+	if !bytes.Equal(copied, []byte{0xed, 0xee}) {
+		t.Errorf("copied = %x, want APP13 and APP14", copied)
+	}
+
+	var markers []byte
+	for _, s := range segmentsOf(t, out) {
+		if !s.isApp() {
+			continue
+		}
+		markers = append(markers, s.Marker)
+		switch s.Marker {
+		case 0xed:
+			if !bytes.Equal(s.Data, photoshop.Data) {
+				t.Errorf("APP13 = %q, want the source segment", s.Data)
+			}
+		case 0xee:
+			// The grayscale output needs no transform
+			if s.Data[adobeTransformOffset] != 0 || !bytes.Equal(s.Data[:adobeTransformOffset], adobe.Data[:adobeTransformOffset]) {
+				t.Errorf("APP14 = %x, want the source segment with transform 0", s.Data)
+			}
+		}
+	}
+	if !bytes.Equal(markers, []byte{markerAPP0, markerAPP1, 0xed, 0xee}) {
+		t.Errorf("segment order = %x, want APP0, EXIF, APP13, APP14", markers)
+	}
+	if adobe.Data[adobeTransformOffset] != 2 {
+		t.Error("source segment was modified")
+	}
+}
@@ -0,0 +1,228 @@
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// xmpNamespaces are the namespaces used by mapped properties, by prefix.
+var xmpNamespaces = map[string]string{
+	"dc":        "http://purl.org/dc/elements/1.1/",
+	"xmp":       "http://ns.adobe.com/xap/1.0/",
+	"xmpRights": "http://ns.adobe.com/xap/1.0/rights/",
+	"tiff":      "http://ns.adobe.com/tiff/1.0/",
+	"exif":      "http://ns.adobe.com/exif/1.0/",
+	"exifEX":    "http://cipa.jp/exif/1.0/",
+}
+
+type xmpKind int
+
+const (
+	xmpSimple xmpKind = iota
+	// xmpLangAlt is a language alternative, e.g. dc:title
+	xmpLangAlt
+	// xmpSeq is an ordered array, e.g. dc:creator
+	xmpSeq
+)
+
+type xmpProperty struct {
+	Name     string
+	Kind     xmpKind
+	Value    string
+	Language string
+}
+
+type textMapping struct {
+	Property string
+	Kind     xmpKind
+	// Date converts the value to an XMP date
+	Date bool
+}
+
+// textMappings map PNG keywords (case-insensitive) to XMP properties. The
+// keywords are those of the PNG specification plus the dates ImageMagick
+// writes. "Comment" is written as a JPEG comment instead.
+var textMappings = map[string]textMapping{
+	"title":         {Property: "dc:title", Kind: xmpLangAlt},
+	"author":        {Property: "dc:creator", Kind: xmpSeq},
+	"description":   {Property: "dc:description", Kind: xmpLangAlt},
+	"copyright":     {Property: "dc:rights", Kind: xmpLangAlt},
+	"disclaimer":    {Property: "xmpRights:UsageTerms", Kind: xmpLangAlt},
+	"software":      {Property: "xmp:CreatorTool"},
+	"source":        {Property: "tiff:Model"},
+	"creation time": {Property: "xmp:CreateDate", Date: true},
+	"date:create":   {Property: "xmp:CreateDate", Date: true},
+	"date:modify":   {Property: "xmp:ModifyDate", Date: true},
+}
+
+// textDateLayouts are the date formats found in PNG text chunks, RFC 1123 is
+// recommended by the PNG specification.
+var textDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006:01:02 15:04:05",
+	"2006-01-02 15:04:05",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+}
+
+// xmpProperties returns the XMP properties equivalent to the text entries and
+// color information of m. The first entry of a property and language wins.
+func (m Metadata) xmpProperties() []xmpProperty {
+	var properties []xmpProperty
+	seen := map[string]bool{}
+	add := func(p xmpProperty) {
+		key := p.Name + "\x00" + p.Language
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		properties = append(properties, p)
+	}
+	for _, entry := range m.Text {
+		mapping, ok := textMappings[strings.ToLower(entry.Keyword)]
+		if !ok || strings.TrimSpace(entry.Text) == "" {
+			continue
+		}
+		value := entry.Text
+		if mapping.Date {
+			date, ok := parseTextDate(value)
+			if !ok {
+				continue
+			}
+			value = date
+		}
+		language := ""
+		if mapping.Kind == xmpLangAlt {
+			language = entry.Language
+		}
+		add(xmpProperty{Name: mapping.Property, Kind: mapping.Kind, Value: value, Language: language})
+	}
+	if m.Gamma > 0 {
+		add(xmpProperty{Name: "exifEX:Gamma", Value: strconv.FormatFloat(m.Gamma, 'f', 2, 64)})
+	}
+	if m.SRGB && len(m.ICC) == 0 {
+		// EXIF ColorSpace 1 is sRGB
+		add(xmpProperty{Name: "exif:ColorSpace", Value: "1"})
+	}
+	return properties
+}
+
+func parseTextDate(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range textDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("2006-01-02T15:04:05-07:00"), true
+		}
+	}
+	return "", false
+}
+
+// mergedXMP returns the XMP packet of m extended by the mapped properties.
+// Properties already present in the packet are left unchanged.
+func (m Metadata) mergedXMP() ([]byte, error) {
+	properties := m.xmpProperties()
+	if len(m.XMP) == 0 {
+		if len(properties) == 0 {
+			return nil, nil
+		}
+		var packet bytes.Buffer
+		packet.WriteString("<?xpacket begin=\"\xef\xbb\xbf\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+		packet.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+		packet.WriteString(" <rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+		packet.Write(xmpDescription(properties))
+		packet.WriteString(" </rdf:RDF>\n</x:xmpmeta>\n<?xpacket end=\"w\"?>")
+		return packet.Bytes(), nil
+	}
+
+	var missing []xmpProperty
+	for _, p := range properties {
+		if !bytes.Contains(m.XMP, []byte("<"+p.Name)) && !bytes.Contains(m.XMP, []byte(" "+p.Name+"=")) {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) == 0 {
+		return m.XMP, nil
+	}
+	end := bytes.LastIndex(m.XMP, []byte("</rdf:RDF>"))
+	if end < 0 {
+		return m.XMP, fmt.Errorf("xmp packet has no rdf:RDF element, text chunks not merged")
+	}
+	merged := make([]byte, 0, len(m.XMP)+512)
+	merged = append(merged, m.XMP[:end]...)
+	merged = append(merged, xmpDescription(missing)...)
+	merged = append(merged, m.XMP[end:]...)
+	return merged, nil
+}
+
+// xmpDescription serializes properties as an rdf:Description element. An XMP
+// packet may hold several of them, which is how properties are added to an
+// existing packet without parsing it.
+func xmpDescription(properties []xmpProperty) []byte {
+	prefixes := map[string]bool{}
+	for _, p := range properties {
+		prefix, _, _ := strings.Cut(p.Name, ":")
+		prefixes[prefix] = true
+	}
+	var sorted []string
+	for prefix := range prefixes {
+		sorted = append(sorted, prefix)
+	}
+	sort.Strings(sorted)
+
+	var b bytes.Buffer
+	b.WriteString("  <rdf:Description rdf:about=\"\"")
+	for _, prefix := range sorted {
+		fmt.Fprintf(&b, "\n    xmlns:%s=\"%s\"", prefix, xmpNamespaces[prefix])
+	}
+	b.WriteString(">\n")
+
+	// Language alternatives of one property share a single rdf:Alt
+	written := map[string]bool{}
+	for _, p := range properties {
+		if written[p.Name] {
+			continue
+		}
+		written[p.Name] = true
+		switch p.Kind {
+		case xmpSimple:
+			fmt.Fprintf(&b, "   <%s>%s</%s>\n", p.Name, xmlEscape(p.Value), p.Name)
+		case xmpSeq:
+			fmt.Fprintf(&b, "   <%s>\n    <rdf:Seq>\n", p.Name)
+			for _, q := range properties {
+				if q.Name == p.Name {
+					fmt.Fprintf(&b, "     <rdf:li>%s</rdf:li>\n", xmlEscape(q.Value))
+				}
+			}
+			fmt.Fprintf(&b, "    </rdf:Seq>\n   </%s>\n", p.Name)
+		case xmpLangAlt:
+			fmt.Fprintf(&b, "   <%s>\n    <rdf:Alt>\n", p.Name)
+			for _, q := range properties {
+				if q.Name != p.Name {
+					continue
+				}
+				language := q.Language
+				if language == "" {
+					language = "x-default"
+				}
+				fmt.Fprintf(&b, "     <rdf:li xml:lang=\"%s\">%s</rdf:li>\n", xmlEscape(language), xmlEscape(q.Value))
+			}
+			fmt.Fprintf(&b, "    </rdf:Alt>\n   </%s>\n", p.Name)
+		}
+	}
+	b.WriteString("  </rdf:Description>\n")
+	return b.Bytes()
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,6 +11,7 @@ import (
 	update "github.com/dhcgn/gh-update"
 	"github.com/dhcgn/jpegli-windows-explorer-extension/convert"
 	"github.com/dhcgn/jpegli-windows-explorer-extension/filehandling"
+	"github.com/dhcgn/jpegli-windows-explorer-extension/fileindex"
 	"github.com/dhcgn/jpegli-windows-explorer-extension/install"
 	"github.com/dhcgn/jpegli-windows-explorer-extension/settings"
 	"github.com/dhcgn/jpegli-windows-explorer-extension/types"
@@ -20,6 +22,13 @@ const (
 	AppName = "jpegli-windows-explorer-extension"
 )
 
+// Values of the non_jpeg_override_policy setting
+const (
+	nonJpegOverrideSibling = "sibling"
+	nonJpegOverrideReplace = "replace"
+	nonJpegOverrideSkip    = "skip"
+)
+
 const (
 	ExitCodeSuccess         = 0
 	ExitCodeSettingsError   = 1
@@ -239,8 +248,43 @@ func showSettings(tools *types.ExecutablePaths, opts settings.Settings, cfgPath
 	pterm.Info.Printfln("Exiftool config: %s", tools.ExiftoolConfig)
 	pterm.Info.Printfln("cjpegli path:    %s", tools.Cjpegli)
 	pterm.Info.Printfln("Jpegli Distance: %.2f (recommended 0.5-3.0, 1.0 = visually lossless, lower better)", opts.Distance)
-	pterm.Info.Printfln("Override Original: %v", opts.OverrideOriginalFile)
+	pterm.Info.Printfln("Override Original: %v (non-JPEG files: %s)", opts.OverrideOriginalFile, opts.NonJpegOverridePolicy)
 	pterm.Info.Printfln("Always Reprocess Files: %v", opts.AlwaysReprocessFiles)
+	pterm.Info.Printfln("Reprocess Policy: %s", reprocessCriteria(opts).Policy)
+	if opts.ReprocessMinVersion != "" {
+		pterm.Info.Printfln("Reprocess Min Version: %s", opts.ReprocessMinVersion)
+	}
+	pterm.Info.Printfln("Use Index: %v", opts.UseIndex)
+	pterm.Info.Printfln("Verify Metadata: %v", opts.VerifyMetadata)
+	pterm.Info.Printfln("Preserve Timestamps: %v, Permissions: %v, Extended Attributes: %v",
+		opts.PreserveTimestamps, opts.PreservePermissions, opts.PreserveExtendedAttributes)
+	pterm.Info.Printfln("Link Policy: %s", opts.LinkPolicy)
+	pterm.Info.Printfln("Multi Picture Policy: %s", opts.MultiPicturePolicy)
+	pterm.Info.Printfln("Animation Policy: %s", opts.AnimationPolicy)
+	pterm.Info.Printfln("Alpha Policy: %s, Background: %s", opts.AlphaPolicy, opts.AlphaBackground)
+	pterm.Info.Printfln("Unusual JPEG Policy: %s", opts.UnusualJPEGPolicy)
+	pterm.Info.Printfln("Min Source Quality: %d, Match Source Quality: %v", opts.MinSourceQuality, opts.MatchSourceQuality)
+	pterm.Info.Printfln("Detect Grayscale: %v, Grayscale Tolerance: %d", opts.DetectGrayscale, opts.GrayscaleTolerance)
+	pterm.Info.Printfln("Normalize Orientation: %v, Thumbnail Policy: %s", opts.NormalizeOrientation, opts.ThumbnailPolicy)
+	if len(opts.TransplantSegments) > 0 {
+		pterm.Info.Printfln("Transplant Segments: %v", opts.TransplantSegments)
+	}
+	pterm.Info.Printfln("Output Exists Policy: %s", opts.OutputExistsPolicy)
+	pterm.Info.Printfln("Profile: %s, Output Root: %s", opts.Profile, valueOrDefault(opts.OutputRoot, "next to source"))
+	pterm.Info.Printfln("Name Templates: file %q, override %q, folder %q, folder file %q",
+		opts.FileNameTemplate, opts.OverrideNameTemplate, opts.FolderNameTemplate, opts.FolderFileNameTemplate)
+	pterm.Info.Printfln("Mirror Other Files: %s", opts.MirrorOtherFiles)
+	pterm.Info.Printfln("Sidecar Policy: %s", opts.SidecarPolicy)
+	pterm.Info.Printfln("Metadata Policy: %s", opts.MetadataPolicy)
+	if opts.MetadataPolicy == string(convert.MetadataCustom) {
+		pterm.Info.Printfln("Metadata Tags: allow %v, deny %v", opts.MetadataAllowTags, opts.MetadataDenyTags)
+	}
+	if opts.Artist != "" || opts.Copyright != "" || opts.Credit != "" || len(opts.SetTags) > 0 {
+		pterm.Info.Printfln("Set Tags: artist %q, copyright %q, credit %q, custom %v", opts.Artist, opts.Copyright, opts.Credit, opts.SetTags)
+	}
+	if opts.OutputLayoutTemplate != "" {
+		pterm.Info.Printfln("Output Layout: %q, fallback %q", opts.OutputLayoutTemplate, opts.OutputLayoutFallback)
+	}
 	pterm.DefaultHeader.Println("Converting")
 }
 
@@ -314,124 +358,571 @@ func checkIsDirOrExit(filesOrDirs []string) (*bool, error) {
 }
 
 func convertFilesOrExit(files []string, isDir bool, tools *types.ExecutablePaths, opts settings.Settings, targetDirBase string) []convert.ConvertStats {
-	if !isDir {
-		return convertSingleFiles(files, tools, opts)
+	idx := openIndex(opts)
+	defer func() {
+		if err := idx.Save(); err != nil {
+			pterm.Warning.Printfln("Could not save index: %s", err)
+		}
+	}()
+	if !usesOutputFolder(isDir, opts) {
+		if isDir {
+			pterm.Info.Printfln("Replacing originals in %s in place, no output folder is created.", targetDirBase)
+		}
+		return convertSingleFiles(files, tools, opts, idx)
 	}
-	return convertDirectory(files, tools, opts, targetDirBase)
+	return convertDirectory(files, tools, opts, targetDirBase, idx)
 }
 
-// convertSingleFiles processes a list of individual files.
-func convertSingleFiles(files []string, tools *types.ExecutablePaths, opts settings.Settings) []convert.ConvertStats {
+// openIndex opens the metadata index in the app folder, or returns nil if the
+// index is disabled or unavailable, which makes every lookup miss.
+func openIndex(opts settings.Settings) *fileindex.Index {
+	if !opts.UseIndex {
+		return nil
+	}
+	appFolder := install.GetAppFolder()
+	if appFolder == "" {
+		return nil
+	}
+	idx, err := fileindex.Open(filepath.Join(appFolder, fileindex.FileName))
+	if err != nil {
+		pterm.Warning.Printfln("Starting with an empty index: %s", err)
+	}
+	return idx
+}
+
+// usesOutputFolder reports whether a run writes into a separate output folder.
+// Folder runs with OverrideOriginalFile replace the originals in place, exactly
+// like a run on the individual files.
+func usesOutputFolder(isDir bool, opts settings.Settings) bool {
+	return isDir && !opts.OverrideOriginalFile
+}
+
+// errSkipOutput marks a file that is deliberately not converted, the wrapping error holds the reason.
+var errSkipOutput = errors.New("skipped")
+
+// convertSingleFiles processes a list of individual files, or the files of a
+// folder whose originals are replaced in place.
+func convertSingleFiles(files []string, tools *types.ExecutablePaths, opts settings.Settings, idx *fileindex.Index) []convert.ConvertStats {
 	states := []convert.ConvertStats{}
 	skippedCount := 0
 	markerValue := optimizedByValue()
-	for _, file := range files {
-		skip, optimizedBy, err := shouldSkipFile(file, tools, opts)
-		if err != nil {
-			pterm.Warning.Printfln("Could not read processed marker for file %s, continuing conversion: %s", file, err)
-		}
-		if skip {
-			pterm.Info.Printfln("Skipped already processed file: %s (processed by: %s)", file, optimizedBy)
+
+	runDate := time.Now()
+	planned, err := planOutputs(files, tools, opts, idx, func(file string, metadata convert.FileMetadata) (string, error) {
+		return singleFileTargetPath(file, metadata, opts, runDate)
+	}, nil)
+	if err != nil {
+		pterm.Error.Printfln("Error planning outputs: %s", err)
+		return nil
+	}
+	resolved, err := filehandling.ResolveOutputCollisions(planned, filehandling.OutputExistsPolicy(opts.OutputExistsPolicy), filehandling.FileExists)
+	if err != nil {
+		pterm.Error.Printfln("Error planning outputs: %s", err)
+		return nil
+	}
+
+	for _, out := range resolved {
+		if out.Skip {
+			pterm.Info.Printfln("Skipped %s: %s", out.Source, out.SkipReason)
 			skippedCount++
 			continue
 		}
 
-		var targetPath string
-
-		ext := strings.ToLower(filepath.Ext(file))
-		isJpeg := ext == ".jpg" || ext == ".jpeg"
-		shouldOverride := opts.OverrideOriginalFile && isJpeg
-
-		if shouldOverride {
-			// When overriding, use the source file as the target
-			targetPath = file
-		} else if opts.OverrideOriginalFile && !isJpeg {
-			// Different file type -> create a new file with extension jpg
-			targetPath = file + ".jpg"
-		} else {
-			// When not overriding, create a new file with .jpegli.jpg suffix
-			baseName := filepath.Base(file)
-			ext := filepath.Ext(baseName)
-			targetName := strings.TrimSuffix(baseName, ext) + ".jpegli.jpg"
-			targetPath = filepath.Join(filepath.Dir(file), targetName)
+		shouldOverride := opts.OverrideOriginalFile && isJpegFile(out.Source)
+		stat, err := convert.Convert(*tools, convertOptions(opts, shouldOverride), out.Source, out.Target, markerValue)
+		if reason, ok := skipReason(err, opts); ok {
+			pterm.Warning.Printfln("Skipped file (%s): %s", reason, err)
+			skippedCount++
+			continue
 		}
-		stat, err := convert.Convert(*tools, opts.Distance, shouldOverride, file, targetPath, markerValue)
 		if err != nil {
 			pterm.Error.Printfln("Error converting file: %s", err)
 			return nil
 		}
 		states = append(states, stat)
-		pterm.Info.Printfln("Converted file: %s with ratio %.2f", file, stat.FileSizeRatio)
+		reportConverted(out.Source, stat)
+		recordOutput(idx, out.Source, outputPath(out, shouldOverride), stat, opts)
+
+		if shouldRemoveSource(out, stat, opts) {
+			if err := os.Remove(out.Source); err != nil {
+				pterm.Warning.Printfln("Could not remove replaced original %s: %s", out.Source, err)
+			} else {
+				idx.Forget(out.Source)
+				pterm.Info.Printfln("Replaced original %s with %s", out.Source, out.Target)
+			}
+		} else if stat.FramesExported > 0 && shouldRemoveSource(out, convert.ConvertStats{}, opts) {
+			pterm.Info.Printfln("Kept original %s, its frames were written to %s", out.Source, stat.FramesFolder)
+		}
 	}
 	if skippedCount > 0 {
-		pterm.Info.Printfln("Skipped %d already processed file(s).", skippedCount)
+		pterm.Info.Printfln("Skipped %d file(s).", skippedCount)
 	}
 	return states
 }
 
+// shouldRemoveSource reports whether a converted non-JPEG original is deleted
+// because its JPEG replaces it (non_jpeg_override_policy: replace).
+func shouldRemoveSource(out filehandling.PlannedOutput, stat convert.ConvertStats, opts settings.Settings) bool {
+	// Exported frames don't replace an animation, their timing would be lost
+	return stat.FramesExported == 0 &&
+		opts.OverrideOriginalFile &&
+		!isJpegFile(out.Source) &&
+		opts.NonJpegOverridePolicy == nonJpegOverrideReplace &&
+		out.Target != out.Source
+}
+
+// singleFileTargetPath returns the output path for a file given as a single argument.
+func singleFileTargetPath(file string, metadata convert.FileMetadata, opts settings.Settings, runDate time.Time) (string, error) {
+	isJpeg := isJpegFile(file)
+	if opts.OverrideOriginalFile && isJpeg {
+		// When overriding, use the source file as the target
+		return file, nil
+	}
+
+	tokens := nameTokens(file, metadata, opts, runDate)
+	if opts.OverrideOriginalFile {
+		// Different file type -> create a new jpg file next to the original,
+		// which replaces the original afterwards with non_jpeg_override_policy: replace.
+		// An empty policy keeps the sibling output of older settings files
+		switch opts.NonJpegOverridePolicy {
+		case "", nonJpegOverrideSibling, nonJpegOverrideReplace:
+		case nonJpegOverrideSkip:
+			return "", fmt.Errorf("%w: not a JPEG, originals are only replaced for JPEG files (non_jpeg_override_policy: skip)", errSkipOutput)
+		default:
+			return "", fmt.Errorf("unknown non_jpeg_override_policy: %q", opts.NonJpegOverridePolicy)
+		}
+		name, err := renderFileName(opts.OverrideNameTemplate, tokens)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(filepath.Dir(file), name), nil
+	}
+
+	// When not overriding, create a new file, by default with .jpegli.jpg suffix
+	name, err := renderFileName(opts.FileNameTemplate, tokens)
+	if err != nil {
+		return "", err
+	}
+	layout, err := layoutFolder(tokens, opts)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filehandling.ResolveOutputRoot(opts.OutputRoot, filepath.Dir(file)), layout, name), nil
+}
+
+// nameTokens collects the naming template values for file.
+func nameTokens(file string, metadata convert.FileMetadata, opts settings.Settings, runDate time.Time) filehandling.NameTokens {
+	tokens := filehandling.NewNameTokens(file, opts.Profile, opts.Distance, runDate)
+	tokens.CaptureDate = metadata.CaptureDate
+	tokens.Camera = metadata.Camera
+	tokens.Lens = metadata.Lens
+	return tokens
+}
+
+// layoutFolder returns the sub folder an output is sorted into according to
+// OutputLayoutTemplate. Files lacking the EXIF values the template needs go to
+// OutputLayoutFallback, or directly into the output folder if that is empty.
+func layoutFolder(tokens filehandling.NameTokens, opts settings.Settings) (string, error) {
+	if opts.OutputLayoutTemplate == "" {
+		return "", nil
+	}
+	folder, err := filehandling.RenderTemplate(opts.OutputLayoutTemplate, tokens)
+	if !errors.Is(err, filehandling.ErrTokenUnavailable) {
+		return folder, err
+	}
+	if opts.OutputLayoutFallback == "" {
+		return "", nil
+	}
+	return filehandling.RenderTemplate(opts.OutputLayoutFallback, tokens)
+}
+
+// renderFileName renders a file name template for tokens. A file lacking an
+// EXIF value the template uses is skipped with the reason, so it does not stop
+// the run. Unlike the folder layout there is no fallback, it would give all
+// such files the same name.
+func renderFileName(template string, tokens filehandling.NameTokens) (string, error) {
+	name, err := filehandling.RenderTemplate(template, tokens)
+	if errors.Is(err, filehandling.ErrTokenUnavailable) {
+		return "", fmt.Errorf("%w: %w in file name template %q", errSkipOutput, err, template)
+	}
+	return name, err
+}
+
+// directoryTargetFolder returns the output folder for a directory run.
+func directoryTargetFolder(dir string, opts settings.Settings, runDate time.Time) (string, error) {
+	// A relative folder like "." has no name of its own to build on
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	tokens := filehandling.NewNameTokens(dir, opts.Profile, opts.Distance, runDate)
+	tokens.Dir = filepath.Base(dir)
+	name, err := filehandling.RenderTemplate(opts.FolderNameTemplate, tokens)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filehandling.ResolveOutputRoot(opts.OutputRoot, filepath.Dir(dir)), name), nil
+}
+
+// folderFileTargetPath returns the output path of a file inside the output folder of a folder run.
+func folderFileTargetPath(file string, metadata convert.FileMetadata, targetFolder string, opts settings.Settings, runDate time.Time) (string, error) {
+	tokens := nameTokens(file, metadata, opts, runDate)
+	name, err := renderFileName(opts.FolderFileNameTemplate, tokens)
+	if err != nil {
+		return "", err
+	}
+	layout, err := layoutFolder(tokens, opts)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(targetFolder, layout, name), nil
+}
+
 // convertDirectory processes all files in a directory, creating a new output directory.
-func convertDirectory(files []string, tools *types.ExecutablePaths, opts settings.Settings, targetDirBase string) []convert.ConvertStats {
+func convertDirectory(files []string, tools *types.ExecutablePaths, opts settings.Settings, targetDirBase string, idx *fileindex.Index) []convert.ConvertStats {
 	states := []convert.ConvertStats{}
 	skippedCount := 0
 	markerValue := optimizedByValue()
-	targetFolder := targetDirBase + "_jpegli-optimized"
-	err := os.MkdirAll(targetFolder, os.ModePerm)
+	runDate := time.Now()
+	targetFolder, err := directoryTargetFolder(targetDirBase, opts, runDate)
+	if err != nil {
+		pterm.Error.Printfln("Error planning outputs: %s", err)
+		return nil
+	}
+
+	check, _ := pterm.DefaultProgressbar.WithTotal(len(files)).WithTitle("Checking files").Start()
+	planned, err := planOutputs(files, tools, opts, idx, func(file string, metadata convert.FileMetadata) (string, error) {
+		return folderFileTargetPath(file, metadata, targetFolder, opts, runDate)
+	}, func() { check.Increment() })
+	check.Stop()
+	if err != nil {
+		pterm.Error.Printfln("Error planning outputs: %s", err)
+		return nil
+	}
+	resolved, err := filehandling.ResolveOutputCollisions(planned, filehandling.OutputExistsPolicy(opts.OutputExistsPolicy), filehandling.FileExists)
+	if err != nil {
+		pterm.Error.Printfln("Error planning outputs: %s", err)
+		return nil
+	}
+
+	err = os.MkdirAll(targetFolder, os.ModePerm)
 	if err != nil {
 		pterm.Error.Printfln("Error creating target folder: %s", err)
 		return nil
 	}
-	p, _ := pterm.DefaultProgressbar.WithTotal(len(files)).WithTitle("Converting files").Start()
-	for _, file := range files {
-		skip, optimizedBy, err := shouldSkipFile(file, tools, opts)
-		if err != nil {
-			pterm.Warning.Printfln("Could not read processed marker for file %s, continuing conversion: %s", file, err)
-		}
-		if skip {
-			pterm.Info.Printfln("Skipped already processed file: %s (processed by: %s)", file, optimizedBy)
+	converted := map[string]bool{}
+	p, _ := pterm.DefaultProgressbar.WithTotal(len(resolved)).WithTitle("Converting files").Start()
+	for _, out := range resolved {
+		if out.Skip {
+			pterm.Info.Printfln("Skipped %s: %s", out.Source, out.SkipReason)
 			skippedCount++
 			p.Increment()
 			continue
 		}
 
-		p.UpdateTitle(fmt.Sprintf("Converting %s", file))
-		baseName := filepath.Base(file)
-		ext := strings.ToLower(filepath.Ext(baseName))
-		if ext != ".jpg" && ext != ".jpeg" {
-			baseName = strings.TrimSuffix(baseName, ext) + ".jpg"
+		p.UpdateTitle(fmt.Sprintf("Converting %s", out.Source))
+		stat, err := convert.Convert(*tools, convertOptions(opts, false), out.Source, out.Target, markerValue)
+		if reason, ok := skipReason(err, opts); ok {
+			if errors.Is(err, convert.ErrTransparentSkipped) && convert.AlphaPolicy(opts.AlphaPolicy) == convert.AlphaKeepPNG {
+				if kept, err := keepOriginal(out.Source, out.Target); err != nil {
+					pterm.Warning.Printfln("Could not keep transparent image %s: %s", out.Source, err)
+				} else {
+					converted[out.Source] = true
+					pterm.Info.Printfln("Kept transparent image %s as %s (alpha_policy: keep_png)", out.Source, kept)
+				}
+			} else {
+				pterm.Warning.Printfln("Skipped file (%s): %s", reason, err)
+			}
+			skippedCount++
+			p.Increment()
+			continue
 		}
-		targetFilePath := targetFolder + string(os.PathSeparator) + baseName
-		stat, err := convert.Convert(*tools, opts.Distance, opts.OverrideOriginalFile, file, targetFilePath, markerValue)
 		if err != nil {
 			pterm.Error.Printfln("Error converting file: %s", err)
 			return nil
 		} else {
 			states = append(states, stat)
-			pterm.Info.Printfln("Converted file: %s with ratio %.2f", file, stat.FileSizeRatio)
+			converted[out.Source] = true
+			if convert.SidecarPolicy(opts.SidecarPolicy) == convert.SidecarCopy {
+				// Already copied next to the output, under its name
+				for _, sidecar := range stat.Sidecars {
+					converted[sidecar] = true
+				}
+			}
+			reportConverted(out.Source, stat)
+			recordOutput(idx, out.Source, out.Target, stat, opts)
 		}
 		p.Increment()
 	}
 	p.Stop()
 	pterm.Info.Printfln("Converted %d file(s) to %s", len(states), targetFolder)
 	if skippedCount > 0 {
-		pterm.Info.Printfln("Skipped %d already processed file(s).", skippedCount)
+		pterm.Info.Printfln("Skipped %d file(s).", skippedCount)
 	}
+	mirrorOtherFiles(targetDirBase, targetFolder, converted, opts.MirrorOtherFiles)
 	return states
 }
 
+// skipReason reports whether err is returned by convert.Convert for a file a
+// policy leaves untouched, and names the setting that skipped it.
+func skipReason(err error, opts settings.Settings) (string, bool) {
+	switch {
+	case errors.Is(err, convert.ErrLinkedFileSkipped):
+		return "link_policy: skip", true
+	case errors.Is(err, convert.ErrMultiPictureSkipped):
+		return "multi_picture_policy: skip", true
+	case errors.Is(err, convert.ErrAnimationSkipped):
+		return "animation_policy: skip", true
+	case errors.Is(err, convert.ErrUnusualJPEGSkipped):
+		return fmt.Sprintf("unusual_jpeg_policy: %s", valueOrDefault(opts.UnusualJPEGPolicy, string(convert.UnusualJPEGSkip))), true
+	case errors.Is(err, convert.ErrLowQualitySkipped):
+		return fmt.Sprintf("min_source_quality: %d", opts.MinSourceQuality), true
+	case errors.Is(err, convert.ErrTransparentSkipped):
+		return fmt.Sprintf("alpha_policy: %s", opts.AlphaPolicy), true
+	}
+	return "", false
+}
+
+// keepOriginal copies a source unchanged into the output folder, named like
+// its JPEG output would have been but with the original extension.
+func keepOriginal(source, target string) (string, error) {
+	kept := strings.TrimSuffix(target, filepath.Ext(target)) + filepath.Ext(source)
+	return kept, filehandling.MirrorFile(source, kept, filehandling.MirrorCopy)
+}
+
+// mirrorOtherFiles carries all files of dir that were not converted (other file
+// types and skipped images) over into targetFolder, so it can replace dir.
+// converted also holds the files carried over along with a conversion, like
+// copied sidecars. Files whose name is taken by an output are reported, as the
+// folder is then no complete replacement of dir.
+func mirrorOtherFiles(dir, targetFolder string, converted map[string]bool, mirrorSetting string) {
+	mode, err := filehandling.ParseMirrorMode(mirrorSetting)
+	if err != nil {
+		pterm.Warning.Printfln("Not mirroring other files: %s", err)
+		return
+	}
+	if mode == filehandling.MirrorNone {
+		return
+	}
+	files, err := filehandling.GetAllFilesInDirectory(nil, dir, func(string) {})
+	if err != nil {
+		pterm.Warning.Printfln("Could not list files to mirror: %s", err)
+		return
+	}
+
+	mirrored := 0
+	for _, file := range files {
+		if converted[file] {
+			continue
+		}
+		target := filepath.Join(targetFolder, filepath.Base(file))
+		if filehandling.FileExists(target) {
+			pterm.Warning.Printfln("Could not mirror %s: %s is already taken by a converted file", file, target)
+			continue
+		}
+		if err := filehandling.MirrorFile(file, target, mode); err != nil {
+			pterm.Warning.Printfln("Could not mirror %s: %s", file, err)
+			continue
+		}
+		mirrored++
+	}
+	pterm.Info.Printfln("Mirrored %d other file(s) to %s (%s)", mirrored, targetFolder, mode)
+}
+
+// planOutputs decides for every file whether it is skipped as already processed
+// and which output path it is written to. Metadata is read once per file for
+// both decisions. Nothing is encoded at this stage, so output collisions can be
+// resolved for the whole run up front.
+func planOutputs(files []string, tools *types.ExecutablePaths, opts settings.Settings, idx *fileindex.Index, targetPath func(string, convert.FileMetadata) (string, error), progress func()) ([]filehandling.PlannedOutput, error) {
+	planned := make([]filehandling.PlannedOutput, 0, len(files))
+	for _, file := range files {
+		if progress != nil {
+			progress()
+		}
+		metadata, err := readFileMetadata(file, tools, idx)
+		if err != nil {
+			pterm.Warning.Printfln("Could not read metadata for file %s, continuing conversion: %s", file, err)
+		}
+		skip, reason, err := shouldSkipFile(metadata, opts)
+		if err != nil {
+			return nil, err
+		}
+		if skip {
+			planned = append(planned, filehandling.PlannedOutput{Source: file, Skip: true, SkipReason: reason})
+			continue
+		}
+		target, err := targetPath(file, metadata)
+		if errors.Is(err, errSkipOutput) {
+			planned = append(planned, filehandling.PlannedOutput{Source: file, Skip: true, SkipReason: err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		planned = append(planned, filehandling.PlannedOutput{Source: file, Target: target})
+	}
+	return planned, nil
+}
+
+func isJpegFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".jpg" || ext == ".jpeg"
+}
+
+// convertOptions maps the settings to the options of a single conversion.
+func convertOptions(opts settings.Settings, overrideOriginal bool) convert.Options {
+	return convert.Options{
+		Distance:                   opts.Distance,
+		OverrideOriginal:           overrideOriginal,
+		PreserveTimestamps:         opts.PreserveTimestamps,
+		PreservePermissions:        opts.PreservePermissions,
+		PreserveExtendedAttributes: opts.PreserveExtendedAttributes,
+		LinkPolicy:                 convert.LinkPolicy(opts.LinkPolicy),
+		SidecarPolicy:              convert.SidecarPolicy(opts.SidecarPolicy),
+		MetadataPolicy:             convert.MetadataPolicy(opts.MetadataPolicy),
+		MetadataAllowTags:          opts.MetadataAllowTags,
+		MetadataDenyTags:           opts.MetadataDenyTags,
+		Artist:                     opts.Artist,
+		Copyright:                  opts.Copyright,
+		Credit:                     opts.Credit,
+		SetTags:                    opts.SetTags,
+		Profile:                    opts.Profile,
+		VerifyMetadata:             opts.VerifyMetadata,
+		MultiPicturePolicy:         convert.MultiPicturePolicy(opts.MultiPicturePolicy),
+		TransplantSegments:         opts.TransplantSegments,
+		NormalizeOrientation:       opts.NormalizeOrientation,
+		ThumbnailPolicy:            convert.ThumbnailPolicy(opts.ThumbnailPolicy),
+		AnimationPolicy:            convert.AnimationPolicy(opts.AnimationPolicy),
+		AlphaPolicy:                convert.AlphaPolicy(opts.AlphaPolicy),
+		AlphaBackground:            opts.AlphaBackground,
+		UnusualJPEGPolicy:          convert.UnusualJPEGPolicy(opts.UnusualJPEGPolicy),
+		MinSourceQuality:           opts.MinSourceQuality,
+		MatchSourceQuality:         opts.MatchSourceQuality,
+		DetectGrayscale:            opts.DetectGrayscale,
+		GrayscaleTolerance:         opts.GrayscaleTolerance,
+	}
+}
+
+// reportConverted prints the per-file result of a conversion.
+func reportConverted(file string, stat convert.ConvertStats) {
+	pterm.Info.Printfln("Converted file: %s with ratio %.2f", file, stat.FileSizeRatio)
+	if stat.Metadata != string(convert.MetadataKeepAll) {
+		pterm.Info.Printfln("  Metadata: %s", stat.Metadata)
+	}
+	if len(stat.Sidecars) > 0 {
+		pterm.Info.Printfln("  Sidecars: %s", strings.Join(stat.Sidecars, ", "))
+	}
+	if stat.SourceQuality.Quality != 0 {
+		pterm.Info.Printfln("  Source: %s, encoded at distance %.1f", stat.SourceQuality, stat.Provenance.Distance)
+	}
+	if stat.Grayscale {
+		pterm.Info.Printfln("  Grayscale: encoded as single channel, %.1f KB smaller than in color", float64(stat.GrayscaleSaved)/1024)
+	}
+	if stat.FlattenedOnto != "" {
+		pterm.Info.Printfln("  Transparency: flattened onto %s", stat.FlattenedOnto)
+	}
+	if stat.FramesExported > 0 {
+		pterm.Info.Printfln("  Frames: %d frame(s) written to %s", stat.FramesExported, stat.FramesFolder)
+	}
+	if stat.Oriented != 0 {
+		pterm.Info.Printfln("  Orientation: pixels rotated for EXIF orientation %d", stat.Oriented)
+	}
+	if len(stat.Transplanted) > 0 {
+		pterm.Info.Printfln("  Transplanted segments: %s", strings.Join(stat.Transplanted, ", "))
+	}
+	if diff := stat.MetadataDiff; diff != nil {
+		pterm.Info.Printfln("  Metadata check: %s", diff)
+		if len(diff.Changed) > 0 {
+			pterm.Info.Printfln("    Changed: %s", tagList(diff.Changed))
+		}
+		if len(diff.Lost) > 0 {
+			pterm.Info.Printfln("    Lost: %s", tagList(diff.Lost))
+		}
+	}
+	for _, warning := range stat.Warnings {
+		pterm.Warning.Printfln("  %s", warning)
+	}
+}
+
+// maxReportedTags limits the tag names listed per file in the metadata check.
+const maxReportedTags = 10
+
+func tagList(tags []string) string {
+	if len(tags) <= maxReportedTags {
+		return strings.Join(tags, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(tags[:maxReportedTags], ", "), len(tags)-maxReportedTags)
+}
+
 func optimizedByValue() string {
 	return fmt.Sprintf("%s %s", AppName, Version)
 }
 
-func shouldSkipFile(file string, tools *types.ExecutablePaths, opts settings.Settings) (bool, string, error) {
-	if opts.AlwaysReprocessFiles {
+// readFileMetadata returns the metadata of file from the index if the file is
+// unchanged, and otherwise reads it with exiftool and records it.
+func readFileMetadata(file string, tools *types.ExecutablePaths, idx *fileindex.Index) (convert.FileMetadata, error) {
+	if metadata, ok := idx.Lookup(file); ok {
+		return metadata, nil
+	}
+	metadata, err := convert.ReadFileMetadata(*tools, file)
+	if err != nil {
+		return metadata, err
+	}
+	if err := idx.Record(file, metadata); err != nil {
+		pterm.Warning.Printfln("Could not index %s: %s", file, err)
+	}
+	return metadata, nil
+}
+
+// outputPath returns where Convert wrote out, the source itself when overriding.
+func outputPath(out filehandling.PlannedOutput, override bool) string {
+	if override {
+		return out.Source
+	}
+	return out.Target
+}
+
+// recordOutput indexes a converted output with the metadata of its source plus
+// the marker, so the next run skips it without exiftool. Outputs whose tags
+// were filtered or changed are not indexed, their metadata is read again.
+func recordOutput(idx *fileindex.Index, source, output string, stat convert.ConvertStats, opts settings.Settings) {
+	// Exported frames are written to a folder, there is no output file
+	if stat.FramesExported > 0 {
+		return
+	}
+	metadata, ok := idx.Metadata(source)
+	keepsTags := convert.MetadataPolicy(opts.MetadataPolicy) == convert.MetadataKeepAll &&
+		opts.Artist == "" && opts.Copyright == "" && opts.Credit == "" && len(opts.SetTags) == 0
+	if !ok || !keepsTags {
+		idx.Forget(output)
+		return
+	}
+	if err := idx.Record(output, metadata.WithProvenance(stat.Provenance)); err != nil {
+		pterm.Warning.Printfln("Could not index %s: %s", output, err)
+	}
+}
+
+// shouldSkipFile reports whether a file is left alone because it already
+// carries the processed marker, and why.
+func shouldSkipFile(metadata convert.FileMetadata, opts settings.Settings) (bool, string, error) {
+	if !shouldSkipAlreadyProcessed(metadata.OptimizedBy) {
 		return false, "", nil
 	}
+	reprocess, reason, err := reprocessCriteria(opts).ShouldReprocess(metadata)
+	return !reprocess, reason, err
+}
 
-	optimizedBy, err := convert.ReadOptimizedBy(*tools, file)
-	if err != nil {
-		return false, "", err
+func reprocessCriteria(opts settings.Settings) convert.ReprocessCriteria {
+	policy := convert.ReprocessPolicy(opts.ReprocessPolicy)
+	if opts.AlwaysReprocessFiles {
+		policy = convert.ReprocessAlways
+	}
+	return convert.ReprocessCriteria{
+		Policy:     policy,
+		Distance:   opts.Distance,
+		Profile:    opts.Profile,
+		MinVersion: opts.ReprocessMinVersion,
 	}
-	return shouldSkipAlreadyProcessed(optimizedBy), optimizedBy, nil
 }
 
 func shouldSkipAlreadyProcessed(optimizedBy string) bool {
@@ -457,6 +948,59 @@ func printStats(states []convert.ConvertStats) {
 		float64(totalTargetSize)/(1024*1024))
 	pterm.Info.Printfln("Average compression ratio: %.2f%%",
 		(1-float64(totalTargetSize)/float64(totalSourceSize))*100)
+
+	firstFrame, exported, frames := 0, 0, 0
+	for _, stat := range states {
+		switch {
+		case stat.FramesExported > 0:
+			exported++
+			frames += stat.FramesExported
+		case stat.AnimationFrames > 0:
+			firstFrame++
+		}
+	}
+	if firstFrame > 0 {
+		pterm.Warning.Printfln("Animations: %d file(s) reduced to their first frame (animation_policy: first_frame)", firstFrame)
+	}
+	if exported > 0 {
+		pterm.Info.Printfln("Animations: %d file(s) exported as %d frame(s) (animation_policy: frames)", exported, frames)
+	}
+
+	grayscale, grayscaleSaved := 0, int64(0)
+	for _, stat := range states {
+		if stat.Grayscale {
+			grayscale++
+			grayscaleSaved += stat.GrayscaleSaved
+		}
+	}
+	if grayscale > 0 {
+		pterm.Info.Printfln("Grayscale: %d file(s) encoded as single channel, %.2f MB saved over color", grayscale, float64(grayscaleSaved)/(1024*1024))
+	}
+
+	verified, withLosses := 0, 0
+	for _, stat := range states {
+		if stat.MetadataDiff == nil {
+			continue
+		}
+		verified++
+		if len(stat.MetadataDiff.ImportantLost) > 0 {
+			withLosses++
+		}
+	}
+	if verified > 0 {
+		if withLosses > 0 {
+			pterm.Warning.Printfln("Metadata check: %d of %d file(s) lost important metadata, see the warnings above", withLosses, verified)
+		} else {
+			pterm.Success.Printfln("Metadata check: no important metadata lost in %d file(s)", verified)
+		}
+	}
+}
+
+func valueOrDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
 }
 
 func boolToText(b bool) string {
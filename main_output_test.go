@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dhcgn/jpegli-windows-explorer-extension/convert"
+	"github.com/dhcgn/jpegli-windows-explorer-extension/filehandling"
+	"github.com/dhcgn/jpegli-windows-explorer-extension/settings"
+)
+
+func TestDirectoryTargetFolder(t *testing.T) {
+	runDate := time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)
+	opts := settings.DefaultSettings()
+	t.Chdir(t.TempDir())
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		dir  string
+		want string
+	}{
+		{name: "plain directory", dir: filepath.Join("photos", "trip"), want: filepath.Join(cwd, "photos", "trip_jpegli-optimized")},
+		{name: "trailing separator", dir: filepath.Join("photos", "trip") + string(filepath.Separator), want: filepath.Join(cwd, "photos", "trip_jpegli-optimized")},
+		{name: "current directory", dir: ".", want: filepath.Join(filepath.Dir(cwd), filepath.Base(cwd)+"_jpegli-optimized")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := directoryTargetFolder(tt.dir, opts, runDate)
+			if err != nil {
+				t.Fatalf("directoryTargetFolder() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("directoryTargetFolder(%q) = %q, want %q", tt.dir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSingleFileTargetPath(t *testing.T) {
+	runDate := time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)
+	jpeg := filepath.Join("photos", "a.jpg")
+	png := filepath.Join("photos", "b.png")
+
+	withRoot := settings.DefaultSettings()
+	withRoot.OutputRoot = "web"
+	override := settings.DefaultSettings()
+	override.OverrideOriginalFile = true
+	emptyPolicy := override
+	emptyPolicy.NonJpegOverridePolicy = ""
+
+	tests := []struct {
+		name string
+		file string
+		opts settings.Settings
+		want string
+	}{
+		{name: "sibling output", file: jpeg, opts: settings.DefaultSettings(), want: filepath.Join("photos", "a.jpegli.jpg")},
+		{name: "relative output root", file: jpeg, opts: withRoot, want: filepath.Join("photos", "web", "a.jpegli.jpg")},
+		{name: "override jpeg", file: jpeg, opts: override, want: jpeg},
+		{name: "override non-jpeg", file: png, opts: override, want: filepath.Join("photos", "b.png.jpg")},
+		{name: "override non-jpeg without policy", file: png, opts: emptyPolicy, want: filepath.Join("photos", "b.png.jpg")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := singleFileTargetPath(tt.file, convert.FileMetadata{}, tt.opts, runDate)
+			if err != nil {
+				t.Fatalf("singleFileTargetPath() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("singleFileTargetPath(%q) = %q, want %q", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSingleFileTargetPathWithLayout(t *testing.T) {
+	runDate := time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)
+	file := filepath.Join("photos", "a.jpg")
+	opts := settings.DefaultSettings()
+	opts.OutputLayoutTemplate = "{yyyy}/{yyyy-mm-dd}"
+
+	withExif := convert.FileMetadata{CaptureDate: time.Date(2023, 8, 1, 9, 0, 0, 0, time.UTC)}
+	got, err := singleFileTargetPath(file, withExif, opts, runDate)
+	if err != nil {
+		t.Fatalf("singleFileTargetPath() error = %v", err)
+	}
+	if want := filepath.Join("photos", "2023", "2023-08-01", "a.jpegli.jpg"); got != want {
+		t.Errorf("singleFileTargetPath() = %q, want %q", got, want)
+	}
+
+	got, err = singleFileTargetPath(file, convert.FileMetadata{}, opts, runDate)
+	if err != nil {
+		t.Fatalf("singleFileTargetPath() error = %v", err)
+	}
+	if want := filepath.Join("photos", "unsorted", "a.jpegli.jpg"); got != want {
+		t.Errorf("singleFileTargetPath() without EXIF = %q, want %q", got, want)
+	}
+}
+
+func TestFileNameTemplateWithoutExif(t *testing.T) {
+	runDate := time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)
+	file := filepath.Join("photos", "a.jpg")
+	opts := settings.DefaultSettings()
+	opts.FileNameTemplate = "{yyyy-mm-dd}-{name}.jpg"
+	opts.FolderFileNameTemplate = "{camera}-{name}.jpg"
+
+	withExif := convert.FileMetadata{CaptureDate: time.Date(2023, 8, 1, 9, 0, 0, 0, time.UTC), Camera: "X100V"}
+	got, err := singleFileTargetPath(file, withExif, opts, runDate)
+	if err != nil {
+		t.Fatalf("singleFileTargetPath() error = %v", err)
+	}
+	if want := filepath.Join("photos", "2023-08-01-a.jpg"); got != want {
+		t.Errorf("singleFileTargetPath() = %q, want %q", got, want)
+	}
+
+	// A single file without EXIF is skipped instead of failing the run
+	if _, err := singleFileTargetPath(file, convert.FileMetadata{}, opts, runDate); !errors.Is(err, errSkipOutput) {
+		t.Errorf("singleFileTargetPath() without EXIF error = %v, want %v", err, errSkipOutput)
+	}
+	if _, err := folderFileTargetPath(file, convert.FileMetadata{}, "out", opts, runDate); !errors.Is(err, errSkipOutput) {
+		t.Errorf("folderFileTargetPath() without EXIF error = %v, want %v", err, errSkipOutput)
+	}
+}
+
+func TestOutputPlanCombinations(t *testing.T) {
+	runDate := time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)
+	dir := filepath.Join("photos", "trip")
+	jpeg := filepath.Join(dir, "a.jpg")
+	png := filepath.Join(dir, "b.png")
+	// Output folders are built from the absolute path of the folder
+	targetFolder, err := filepath.Abs(filepath.Join("photos", "trip_jpegli-optimized"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name             string
+		isDir            bool
+		override         bool
+		nonJpegPolicy    string
+		file             string
+		wantOutputFolder bool
+		wantTarget       string
+		wantSkip         bool
+		wantRemoveSource bool
+	}{
+		{name: "file, jpeg", file: jpeg, wantTarget: filepath.Join(dir, "a.jpegli.jpg")},
+		{name: "file, png", file: png, wantTarget: filepath.Join(dir, "b.jpegli.jpg")},
+		{name: "file override, jpeg", override: true, file: jpeg, wantTarget: jpeg},
+		{name: "file override, png sibling", override: true, nonJpegPolicy: "sibling", file: png, wantTarget: filepath.Join(dir, "b.png.jpg")},
+		{name: "file override, png replace", override: true, nonJpegPolicy: "replace", file: png, wantTarget: filepath.Join(dir, "b.png.jpg"), wantRemoveSource: true},
+		{name: "file override, png skip", override: true, nonJpegPolicy: "skip", file: png, wantSkip: true},
+		{name: "folder, jpeg", isDir: true, file: jpeg, wantOutputFolder: true, wantTarget: filepath.Join(targetFolder, "a.jpg")},
+		{name: "folder, png", isDir: true, file: png, wantOutputFolder: true, wantTarget: filepath.Join(targetFolder, "b.jpg")},
+		{name: "folder override, jpeg", isDir: true, override: true, file: jpeg, wantTarget: jpeg},
+		{name: "folder override, png sibling", isDir: true, override: true, nonJpegPolicy: "sibling", file: png, wantTarget: filepath.Join(dir, "b.png.jpg")},
+		{name: "folder override, png replace", isDir: true, override: true, nonJpegPolicy: "replace", file: png, wantTarget: filepath.Join(dir, "b.png.jpg"), wantRemoveSource: true},
+		{name: "folder override, png skip", isDir: true, override: true, nonJpegPolicy: "skip", file: png, wantSkip: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := settings.DefaultSettings()
+			opts.OverrideOriginalFile = tt.override
+			if tt.nonJpegPolicy != "" {
+				opts.NonJpegOverridePolicy = tt.nonJpegPolicy
+			}
+
+			if got := usesOutputFolder(tt.isDir, opts); got != tt.wantOutputFolder {
+				t.Fatalf("usesOutputFolder() = %v, want %v", got, tt.wantOutputFolder)
+			}
+
+			var target string
+			var err error
+			if tt.wantOutputFolder {
+				folder, folderErr := directoryTargetFolder(dir, opts, runDate)
+				if folderErr != nil {
+					t.Fatalf("directoryTargetFolder() error = %v", folderErr)
+				}
+				target, err = folderFileTargetPath(tt.file, convert.FileMetadata{}, folder, opts, runDate)
+			} else {
+				target, err = singleFileTargetPath(tt.file, convert.FileMetadata{}, opts, runDate)
+			}
+
+			if tt.wantSkip {
+				if !errors.Is(err, errSkipOutput) {
+					t.Fatalf("Expected file to be skipped, got target %q, error %v", target, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if target != tt.wantTarget {
+				t.Errorf("Target = %q, want %q", target, tt.wantTarget)
+			}
+			out := filehandling.PlannedOutput{Source: tt.file, Target: target}
+			if got := shouldRemoveSource(out, convert.ConvertStats{}, opts); got != tt.wantRemoveSource {
+				t.Errorf("shouldRemoveSource() = %v, want %v", got, tt.wantRemoveSource)
+			}
+			frames := convert.ConvertStats{FramesExported: 12, FramesFolder: filepath.Join(dir, "b")}
+			if shouldRemoveSource(out, frames, opts) {
+				t.Error("shouldRemoveSource() = true for exported frames, want false")
+			}
+		})
+	}
+}
+
+func TestMirrorOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	targetFolder := t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a.png was converted to a.jpg, the already processed a.jpg was skipped
+	write(filepath.Join(dir, "a.png"), "png")
+	write(filepath.Join(dir, "a.jpg"), "skipped jpeg")
+	write(filepath.Join(dir, "b.jpg"), "jpeg")
+	write(filepath.Join(dir, "b.xmp"), "sidecar")
+	write(filepath.Join(dir, "notes.txt"), "notes")
+	write(filepath.Join(targetFolder, "a.jpg"), "converted png")
+	write(filepath.Join(targetFolder, "b.jpg"), "converted jpeg")
+	write(filepath.Join(targetFolder, "b.xmp"), "copied sidecar")
+	converted := map[string]bool{
+		filepath.Join(dir, "a.png"): true,
+		filepath.Join(dir, "b.jpg"): true,
+		filepath.Join(dir, "b.xmp"): true,
+	}
+
+	mirrorOtherFiles(dir, targetFolder, converted, string(filehandling.MirrorCopy))
+
+	for name, want := range map[string]string{
+		"a.jpg":     "converted png",
+		"b.xmp":     "copied sidecar",
+		"notes.txt": "notes",
+	} {
+		got, err := os.ReadFile(filepath.Join(targetFolder, name))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if filehandling.FileExists(filepath.Join(targetFolder, "a.png")) {
+		t.Error("Converted a.png was mirrored")
+	}
+}
@@ -1,6 +1,13 @@
 package main
 
-import "testing"
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/dhcgn/jpegli-windows-explorer-extension/convert"
+	"github.com/dhcgn/jpegli-windows-explorer-extension/settings"
+)
 
 func TestShouldSkipAlreadyProcessed(t *testing.T) {
 	tests := []struct {
@@ -46,3 +53,35 @@ func TestOptimizedByValueIncludesVersion(t *testing.T) {
 		t.Fatalf("optimizedByValue() = %q, want %q", got, want)
 	}
 }
+
+func TestSkipReason(t *testing.T) {
+	opts := settings.DefaultSettings()
+	opts.UnusualJPEGPolicy = ""
+	opts.MinSourceQuality = 60
+	opts.AlphaPolicy = "keep_png"
+
+	tests := []struct {
+		name     string
+		err      error
+		want     string
+		wantSkip bool
+	}{
+		{name: "linked file", err: convert.ErrLinkedFileSkipped, want: "link_policy: skip", wantSkip: true},
+		{name: "secondary images", err: convert.ErrMultiPictureSkipped, want: "multi_picture_policy: skip", wantSkip: true},
+		{name: "animation", err: convert.ErrAnimationSkipped, want: "animation_policy: skip", wantSkip: true},
+		{name: "unusual jpeg with default policy", err: convert.ErrUnusualJPEGSkipped, want: "unusual_jpeg_policy: skip", wantSkip: true},
+		{name: "low quality", err: convert.ErrLowQualitySkipped, want: "min_source_quality: 60", wantSkip: true},
+		{name: "transparent, wrapped", err: fmt.Errorf("%w: logo.png", convert.ErrTransparentSkipped), want: "alpha_policy: keep_png", wantSkip: true},
+		{name: "conversion error", err: errors.New("cjpegli execution failed")},
+		{name: "no error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, skip := skipReason(tt.err, opts)
+			if got != tt.want || skip != tt.wantSkip {
+				t.Errorf("skipReason() = %q, %v, want %q, %v", got, skip, tt.want, tt.wantSkip)
+			}
+		})
+	}
+}
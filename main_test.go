@@ -27,16 +27,15 @@ func TestMain(m *testing.M) {
 }
 
 func defaultTestingSettings() *settings.Settings {
-	return &settings.Settings{
-		// Default values
-		Distance:             0.5,
-		OverrideOriginalFile: false,
-		AlwaysReprocessFiles: false,
-
-		// Because this is for testing, set these to true
-		SkipUpdateCheck:   true,
-		NoUserInteraction: true,
-	}
+	// Default values
+	opts := settings.DefaultSettings()
+
+	// Because this is for testing, set these to true
+	opts.SkipUpdateCheck = true
+	opts.NoUserInteraction = true
+	// Keep test runs out of the index in the app folder
+	opts.UseIndex = false
+	return &opts
 }
 
 func TestRun_Help(t *testing.T) {
@@ -278,3 +277,54 @@ func TestRun_ConvertFolder(t *testing.T) {
 		t.Errorf("Expected output file %s to be created", pngConvertedFile)
 	}
 }
+
+func TestRun_ConvertFolder_Override(t *testing.T) {
+	files := []string{
+		filepath.Join("test-files", "DSC_4045-NEF_DxO_DeepPRIME.jpg"),
+		filepath.Join("test-files", "Untitled.png"),
+	}
+	testDir, cleanup := prepareTestFolder(t, files)
+	defer cleanup()
+
+	// No output folder must be created in override mode
+	unexpectedOutputFolder := testDir + "_jpegli-optimized"
+	defer os.RemoveAll(unexpectedOutputFolder)
+
+	args := []string{"app", testDir}
+
+	opts := defaultTestingSettings()
+	opts.OverrideOriginalFile = true
+
+	exitCode := Run(args, opts)
+
+	if exitCode != ExitCodeSuccess {
+		t.Errorf("Expected exit code %d, got %d", ExitCodeSuccess, exitCode)
+	}
+
+	if _, err := os.Stat(unexpectedOutputFolder); !os.IsNotExist(err) {
+		t.Errorf("Expected no output folder to be created, but found %s", unexpectedOutputFolder)
+	}
+
+	// Check the JPEG was replaced in place by a smaller file
+	jpgFile := filepath.Join(testDir, "DSC_4045-NEF_DxO_DeepPRIME.jpg")
+	inputStat, err := os.Stat(files[0])
+	if err != nil {
+		t.Fatalf("Failed to stat input file: %v", err)
+	}
+	outputStat, err := os.Stat(jpgFile)
+	if err != nil {
+		t.Fatalf("Expected replaced file %s to exist: %v", jpgFile, err)
+	}
+	if outputStat.Size() >= inputStat.Size() {
+		t.Errorf("Expected replaced file to be smaller. Original: %d, New: %d", inputStat.Size(), outputStat.Size())
+	}
+
+	// Check the png got a sibling jpg (non_jpeg_override_policy: sibling) and was kept
+	pngFile := filepath.Join(testDir, "Untitled.png")
+	if _, err := os.Stat(pngFile + ".jpg"); os.IsNotExist(err) {
+		t.Errorf("Expected output file %s to be created", pngFile+".jpg")
+	}
+	if _, err := os.Stat(pngFile); os.IsNotExist(err) {
+		t.Errorf("Expected original file %s to exist", pngFile)
+	}
+}
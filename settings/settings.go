@@ -13,11 +13,101 @@ const configFileName = "config.yaml"
 
 // Settings represents the configuration options for the application
 type Settings struct {
-	Distance             float64 `yaml:"distance"`
-	OverrideOriginalFile bool    `yaml:"override_original_file"`
-	AlwaysReprocessFiles bool    `yaml:"always_reprocess_files"`
-	SkipUpdateCheck      bool    `yaml:"skip_update_check"`
-	NoUserInteraction    bool    `yaml:"no_user_interaction"`
+	Distance                   float64           `yaml:"distance"`
+	OverrideOriginalFile       bool              `yaml:"override_original_file"`
+	AlwaysReprocessFiles       bool              `yaml:"always_reprocess_files"`
+	ReprocessPolicy            string            `yaml:"reprocess_policy"`
+	ReprocessMinVersion        string            `yaml:"reprocess_min_version"`
+	UseIndex                   bool              `yaml:"use_index"`
+	SkipUpdateCheck            bool              `yaml:"skip_update_check"`
+	NoUserInteraction          bool              `yaml:"no_user_interaction"`
+	PreserveTimestamps         bool              `yaml:"preserve_timestamps"`
+	PreservePermissions        bool              `yaml:"preserve_permissions"`
+	PreserveExtendedAttributes bool              `yaml:"preserve_extended_attributes"`
+	LinkPolicy                 string            `yaml:"link_policy"`
+	OutputExistsPolicy         string            `yaml:"output_exists_policy"`
+	Profile                    string            `yaml:"profile"`
+	OutputRoot                 string            `yaml:"output_root"`
+	FileNameTemplate           string            `yaml:"file_name_template"`
+	OverrideNameTemplate       string            `yaml:"override_name_template"`
+	FolderNameTemplate         string            `yaml:"folder_name_template"`
+	FolderFileNameTemplate     string            `yaml:"folder_file_name_template"`
+	OutputLayoutTemplate       string            `yaml:"output_layout_template"`
+	OutputLayoutFallback       string            `yaml:"output_layout_fallback"`
+	MirrorOtherFiles           string            `yaml:"mirror_other_files"`
+	NonJpegOverridePolicy      string            `yaml:"non_jpeg_override_policy"`
+	SidecarPolicy              string            `yaml:"sidecar_policy"`
+	MetadataPolicy             string            `yaml:"metadata_policy"`
+	MetadataAllowTags          []string          `yaml:"metadata_allow_tags"`
+	MetadataDenyTags           []string          `yaml:"metadata_deny_tags"`
+	Artist                     string            `yaml:"artist"`
+	Copyright                  string            `yaml:"copyright"`
+	Credit                     string            `yaml:"credit"`
+	SetTags                    map[string]string `yaml:"set_tags"`
+	VerifyMetadata             bool              `yaml:"verify_metadata"`
+	MultiPicturePolicy         string            `yaml:"multi_picture_policy"`
+	TransplantSegments         []string          `yaml:"transplant_segments"`
+	NormalizeOrientation       bool              `yaml:"normalize_orientation"`
+	ThumbnailPolicy            string            `yaml:"thumbnail_policy"`
+	AnimationPolicy            string            `yaml:"animation_policy"`
+	AlphaPolicy                string            `yaml:"alpha_policy"`
+	AlphaBackground            string            `yaml:"alpha_background"`
+	UnusualJPEGPolicy          string            `yaml:"unusual_jpeg_policy"`
+	MinSourceQuality           int               `yaml:"min_source_quality"`
+	MatchSourceQuality         bool              `yaml:"match_source_quality"`
+	DetectGrayscale            bool              `yaml:"detect_grayscale"`
+	GrayscaleTolerance         int               `yaml:"grayscale_tolerance"`
+}
+
+// DefaultSettings returns the settings used when no configuration file exists.
+// Options missing in an existing configuration file keep these values.
+func DefaultSettings() Settings {
+	return Settings{
+		Distance:                   0.5,
+		OverrideOriginalFile:       false,
+		AlwaysReprocessFiles:       false,
+		ReprocessPolicy:            "never",
+		ReprocessMinVersion:        "",
+		UseIndex:                   true,
+		SkipUpdateCheck:            false,
+		NoUserInteraction:          false,
+		PreserveTimestamps:         true,
+		PreservePermissions:        true,
+		PreserveExtendedAttributes: true,
+		LinkPolicy:                 "follow",
+		OutputExistsPolicy:         "overwrite",
+		Profile:                    "default",
+		OutputRoot:                 "",
+		FileNameTemplate:           "{name}.jpegli.jpg",
+		OverrideNameTemplate:       "{name}.{ext}.jpg",
+		FolderNameTemplate:         "{dir}_jpegli-optimized",
+		FolderFileNameTemplate:     "{name}.{jpgext}",
+		OutputLayoutTemplate:       "",
+		OutputLayoutFallback:       "unsorted",
+		MirrorOtherFiles:           "none",
+		NonJpegOverridePolicy:      "sibling",
+		SidecarPolicy:              "ignore",
+		MetadataPolicy:             "keep_all",
+		MetadataAllowTags:          []string{},
+		MetadataDenyTags:           []string{},
+		Artist:                     "",
+		Copyright:                  "",
+		Credit:                     "",
+		SetTags:                    map[string]string{},
+		VerifyMetadata:             false,
+		MultiPicturePolicy:         "warn",
+		TransplantSegments:         []string{},
+		NormalizeOrientation:       false,
+		ThumbnailPolicy:            "keep",
+		AnimationPolicy:            "first_frame",
+		AlphaPolicy:                "flatten",
+		AlphaBackground:            "#ffffff",
+		UnusualJPEGPolicy:          "skip",
+		MinSourceQuality:           0,
+		MatchSourceQuality:         false,
+		DetectGrayscale:            false,
+		GrayscaleTolerance:         2,
+	}
 }
 
 func configFilePath() string {
@@ -56,7 +146,7 @@ func LoadOrDefault() (Settings, string, error) {
 }
 
 func loadOrDefault(cfgPath string) (Settings, string, error) {
-	defaultOpts := Settings{Distance: 0.5, OverrideOriginalFile: false, AlwaysReprocessFiles: false, SkipUpdateCheck: false, NoUserInteraction: false}
+	defaultOpts := DefaultSettings()
 
 	if _, err := os.Stat(cfgPath); os.IsNotExist(err) {
 		saveDefaultConfig(cfgPath, defaultOpts)
@@ -72,7 +162,7 @@ func loadOrDefault(cfgPath string) (Settings, string, error) {
 		saveDefaultConfig(cfgPath, defaultOpts)
 		return defaultOpts, cfgPath, err
 	}
-	var opts Settings
+	opts := defaultOpts
 	err = yaml.Unmarshal(data, &opts)
 	if err != nil {
 		saveDefaultConfig(cfgPath, defaultOpts)
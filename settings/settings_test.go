@@ -3,6 +3,7 @@ package settings
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	"gopkg.in/yaml.v3"
@@ -95,3 +96,21 @@ func TestSaveAndLoadConfig(t *testing.T) {
 		t.Errorf("Expected AlwaysReprocessFiles to be %v, got %v", testConfig.AlwaysReprocessFiles, loaded.AlwaysReprocessFiles)
 	}
 }
+
+func TestLoadOrDefaultKeepsDefaultsForMissingKeys(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("distance: 1.5\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	loaded, _, err := loadOrDefault(configPath)
+	if err != nil {
+		t.Fatalf("loadOrDefault() error = %v", err)
+	}
+
+	want := DefaultSettings()
+	want.Distance = 1.5
+	if !reflect.DeepEqual(loaded, want) {
+		t.Errorf("Expected %+v, got %+v", want, loaded)
+	}
+}